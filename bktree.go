@@ -0,0 +1,79 @@
+package main
+
+import "time"
+
+// BKTree indexes words by Levenshtein distance so that all words within a
+// given distance of a query can be found without generating every edit.
+type BKTree struct {
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	word     string
+	children map[int]*bkNode
+	maxEdge  int // largest key in children
+}
+
+func newBKTree() *BKTree {
+	return &BKTree{}
+}
+
+func (t *BKTree) insert(word string) {
+	if t.root == nil {
+		t.root = &bkNode{word: word, children: make(map[int]*bkNode)}
+		t.size++
+		return
+	}
+	node := t.root
+	for {
+		d := levenshteinDistance(word, node.word)
+		if d == 0 {
+			return // already indexed
+		}
+		child, exists := node.children[d]
+		if !exists {
+			node.children[d] = &bkNode{word: word, children: make(map[int]*bkNode)}
+			node.maxEdge = max(node.maxEdge, d)
+			t.size++
+			return
+		}
+		node = child
+	}
+}
+
+// query returns every indexed word within maxDistance of word. It stops
+// early and reports false once deadline has passed; a zero deadline never
+// expires.
+func (t *BKTree) query(word string, maxDistance int, deadline time.Time) ([]Candidate, bool) {
+	var candidates []Candidate
+	if t.root == nil {
+		return candidates, true
+	}
+	stack := []*bkNode{t.root}
+	for visited := 1; len(stack) > 0; visited++ {
+		if visited%deadlineCheckInterval == 0 && pastDeadline(deadline) {
+			return candidates, false
+		}
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		// Past maxDistance+maxEdge no child can be in range, so the exact
+		// distance isn't needed and the comparison can stop early
+		d, ok := DistanceWithin(word, node.word, maxDistance+node.maxEdge)
+		if !ok {
+			continue
+		}
+		if d <= maxDistance {
+			candidates = append(candidates, Candidate{Word: node.word, Distance: d})
+		}
+		// By the triangle inequality only children whose edge distance is
+		// within maxDistance of d can hold matches
+		for edge, child := range node.children {
+			if edge >= d-maxDistance && edge <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return candidates, true
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// BenchmarkClosestMatch compares finding the corrections of benchTypos by
+// generating edits with querying the BK-tree, on the full big_dic.txt.
+func BenchmarkClosestMatch(b *testing.B) {
+	for _, bk := range []bool{false, true} {
+		name := "edits"
+		if bk {
+			name = "bktree"
+		}
+		b.Run(name, func(b *testing.B) {
+			useBenchDictionary(b)
+			config.BKTree, config.WordTimeout = bk, 0
+			typos := benchTypos(b)
+			dictionaryMu.RLock()
+			defer dictionaryMu.RUnlock()
+			for i := 0; i < b.N; i++ {
+				for _, typo := range typos {
+					clearMatchCache()
+					findClosestMatch(typo)
+				}
+			}
+		})
+	}
+}
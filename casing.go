@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// localeCases maps the locales whose casing differs from Unicode's default
+// to their rules: Turkish and Azeri pair I with ı and İ with i.
+var localeCases = map[string]unicode.SpecialCase{
+	"tr": unicode.TurkishCase,
+	"az": unicode.AzeriCase,
+}
+
+// localeCase holds the casing rules of config.Locale, or nil for the
+// default casing. It is set by applyConfig.
+var localeCase unicode.SpecialCase
+
+// setLocale selects the casing rules for locale, such as "tr" or "tr-TR".
+// Locales without special rules, and the empty one, use the default.
+func setLocale(locale string) {
+	language, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	language, _, _ = strings.Cut(language, "_")
+	localeCase = localeCases[language]
+}
+
+// toLower lowercases s by the casing rules of config.Locale.
+func toLower(s string) string {
+	if localeCase == nil {
+		return strings.ToLower(s)
+	}
+	return strings.ToLowerSpecial(localeCase, s)
+}
+
+// toUpper uppercases s by the casing rules of config.Locale.
+func toUpper(s string) string {
+	if localeCase == nil {
+		return strings.ToUpper(s)
+	}
+	return strings.ToUpperSpecial(localeCase, s)
+}
+
+// toUpperRune uppercases r by the casing rules of config.Locale.
+func toUpperRune(r rune) rune {
+	if localeCase == nil {
+		return unicode.ToUpper(r)
+	}
+	return localeCase.ToUpper(r)
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestTurkishLocale(t *testing.T) {
+	useWords(t)
+	config.Locale = "tr"
+	applyConfig()
+	dictionary = newTrieFromWords([]string{"İstanbul", "ırmak", "kitap"})
+
+	for in, want := range map[string]string{"İSTANBUL": "istanbul", "IRMAK": "ırmak", "Kitap": "kitap"} {
+		if got := toLower(in); got != want {
+			t.Errorf("toLower(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got, want := toUpper("istanbul"), "İSTANBUL"; got != want {
+		t.Errorf("toUpper(%q) = %q, want %q", "istanbul", got, want)
+	}
+	assertCorrects(t, map[string]string{
+		"İstanbul":        "İstanbul",
+		"İstanbl":         "İstanbul",
+		"istanbul ırmk":   "İstanbul ırmak",
+		"kitap, İstnbul.": "kitap, İstanbul.",
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Clipboard reads and writes the text on the system clipboard.
+type Clipboard interface {
+	Read() (string, error)
+	Write(text string) error
+}
+
+// clipboard is the platform clipboard used by checkSpelling. main creates
+// it again once the config is loaded, since it may depend on
+// config.Selection.
+var clipboard Clipboard = newClipboard()
+
+// commandClipboard talks to the clipboard through helper programs such as
+// pbcopy/pbpaste or xclip, which read the text on stdout and write it from
+// stdin.
+type commandClipboard struct {
+	readCmd  []string
+	writeCmd []string
+}
+
+func (c commandClipboard) Read() (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.readCmd[0], c.readCmd[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w: %s", c.readCmd[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (c commandClipboard) Write(text string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(c.writeCmd[0], c.writeCmd[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", c.writeCmd[0], err, strings.TrimSpace(stderr.String()))
+	}
+	noteOwnWrite(text)
+	return nil
+}
@@ -0,0 +1,8 @@
+package main
+
+func newClipboard() Clipboard {
+	return commandClipboard{
+		readCmd:  []string{"pbpaste"},
+		writeCmd: []string{"pbcopy"},
+	}
+}
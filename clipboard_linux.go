@@ -0,0 +1,29 @@
+package main
+
+import "os"
+
+// newClipboard uses wl-clipboard under Wayland and xclip under X11. With
+// config.Selection set to "primary", text is read from the PRIMARY
+// selection, which holds whatever is highlighted, so nothing needs to be
+// copied first; corrections still go to CLIPBOARD for pasting.
+func newClipboard() Clipboard {
+	primary := config.Selection == "primary"
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		readCmd := []string{"wl-paste", "--no-newline"}
+		if primary {
+			readCmd = append(readCmd, "--primary")
+		}
+		return commandClipboard{
+			readCmd:  readCmd,
+			writeCmd: []string{"wl-copy"},
+		}
+	}
+	selection := "clipboard"
+	if primary {
+		selection = "primary"
+	}
+	return commandClipboard{
+		readCmd:  []string{"xclip", "-selection", selection, "-o"},
+		writeCmd: []string{"xclip", "-selection", "clipboard", "-i"},
+	}
+}
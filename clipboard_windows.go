@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+var (
+	user32           = syscall.NewLazyDLL("user32.dll")
+	getClipboardData = user32.NewProc("GetClipboardData")
+	openClipboard    = user32.NewProc("OpenClipboard")
+	closeClipboard   = user32.NewProc("CloseClipboard")
+	emptyClipboard   = user32.NewProc("EmptyClipboard")
+	setClipboardData = user32.NewProc("SetClipboardData")
+	enumClipboardFmt = user32.NewProc("EnumClipboardFormats")
+	registerClipFmt  = user32.NewProc("RegisterClipboardFormatW")
+
+	kernel32            = syscall.NewLazyDLL("kernel32.dll")
+	globalSize          = kernel32.NewProc("GlobalSize")
+	multiByteToWideChar = kernel32.NewProc("MultiByteToWideChar")
+	wideCharToMultiByte = kernel32.NewProc("WideCharToMultiByte")
+)
+
+// windowsClipboard uses the Win32 clipboard API directly.
+type windowsClipboard struct{}
+
+func newClipboard() Clipboard {
+	return windowsClipboard{}
+}
+
+func (windowsClipboard) Read() (string, error) {
+	return getClipboardText()
+}
+
+func (windowsClipboard) Write(text string) error {
+	return setClipboardText(text)
+}
+
+func getClipboardText() (string, error) {
+	if r, _, err := openClipboard.Call(0); r == 0 {
+		return "", fmt.Errorf("open clipboard: %w", err)
+	}
+	defer closeClipboard.Call()
+	format := textFormat()
+	if format == 0 {
+		debugf("Clipboard holds no text, skipping")
+		return "", nil
+	}
+	h, _, _ := getClipboardData.Call(format)
+	if h == 0 {
+		return "", nil
+	}
+	p := win.GlobalLock(win.HGLOBAL(h))
+	if p == nil {
+		return "", errors.New("lock clipboard memory")
+	}
+	defer win.GlobalUnlock(win.HGLOBAL(h))
+
+	// Only read within the allocation, and stop at the terminating NUL
+	// since the allocation may be larger than the text
+	size, _, _ := globalSize.Call(h)
+	if format == win.CF_TEXT {
+		return fromCodePage(unsafe.Slice((*byte)(p), size))
+	}
+	units := unsafe.Slice((*uint16)(p), size/2)
+	for i, u := range units {
+		if u == 0 {
+			units = units[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// textFormat returns CF_UNICODETEXT if the open clipboard offers it, CF_TEXT
+// if only ANSI text is there, and 0 for images, copied files and other
+// non-text content.
+func textFormat() uintptr {
+	found := uintptr(0)
+	format := uintptr(0)
+	for {
+		format, _, _ = enumClipboardFmt.Call(format)
+		switch format {
+		case 0:
+			return found
+		case win.CF_UNICODETEXT:
+			return format
+		case win.CF_TEXT:
+			found = format
+		}
+	}
+}
+
+// fromCodePage converts NUL-terminated text in the system ANSI code page to
+// a Go string.
+func fromCodePage(data []byte) (string, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	if len(data) == 0 {
+		return "", nil
+	}
+	n, _, err := multiByteToWideChar.Call(win.CP_ACP, 0, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0, 0)
+	if n == 0 {
+		return "", fmt.Errorf("convert ANSI clipboard text: %w", err)
+	}
+	units := make([]uint16, n)
+	multiByteToWideChar.Call(win.CP_ACP, 0, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&units[0])), n)
+	return string(utf16.Decode(units)), nil
+}
+
+// toCodePage converts NUL-terminated UTF-16 text to the system ANSI code
+// page. Characters the code page lacks become its default character.
+func toCodePage(units []uint16) ([]byte, error) {
+	n, _, err := wideCharToMultiByte.Call(win.CP_ACP, 0, uintptr(unsafe.Pointer(&units[0])), uintptr(len(units)), 0, 0, 0, 0)
+	if n == 0 {
+		return nil, fmt.Errorf("convert clipboard text to ANSI: %w", err)
+	}
+	data := make([]byte, n)
+	wideCharToMultiByte.Call(win.CP_ACP, 0, uintptr(unsafe.Pointer(&units[0])), uintptr(len(units)), uintptr(unsafe.Pointer(&data[0])), n, 0, 0)
+	return data, nil
+}
+
+// setClipboardText puts text on the clipboard as CF_UNICODETEXT and, for
+// older programs that only read ANSI text, as CF_TEXT. With
+// config.PreserveFormats the other formats already there, such as HTML or
+// copied files, are put back alongside it. The text is kept out of the
+// Windows clipboard history when config.ClipboardHistory is off or the
+// program that copied the original kept it out.
+func setClipboardText(text string) error {
+	units, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+	ansi, err := toCodePage(units)
+	if err != nil {
+		return err
+	}
+	if r, _, err := openClipboard.Call(0); r == 0 {
+		return fmt.Errorf("open clipboard: %w", err)
+	}
+	defer closeClipboard.Call()
+	private := excludedFromHistory()
+	var kept []clipboardData
+	if config.PreserveFormats {
+		kept = otherFormats()
+	}
+	// EmptyClipboard is the only way to become the clipboard owner, which
+	// SetClipboardData requires, so kept formats are copied out first
+	emptyClipboard.Call()
+	if err := setClipboardBytes(win.CF_UNICODETEXT, unsafe.Slice((*byte)(unsafe.Pointer(&units[0])), len(units)*2)); err != nil {
+		return err
+	}
+	if err := setClipboardBytes(win.CF_TEXT, ansi); err != nil {
+		warnf("Could not add ANSI text to the clipboard: %v", err)
+	}
+	for _, d := range kept {
+		if err := setClipboardBytes(d.format, d.data); err != nil {
+			warnf("Could not keep clipboard format %d: %v", d.format, err)
+		}
+	}
+	if !config.ClipboardHistory || private {
+		// A zero DWORD keeps the text out of the history and cloud sync;
+		// without the format Windows treats it like any other copy
+		if err := setClipboardBytes(registeredFormat("CanIncludeInClipboardHistory"), []byte{0, 0, 0, 0}); err != nil {
+			warnf("Could not set the clipboard history flag: %v", err)
+		}
+	}
+	noteOwnWrite(text)
+	return nil
+}
+
+// registeredFormat returns the ID of the named clipboard format, registering
+// it if needed.
+func registeredFormat(name string) uintptr {
+	format, _, _ := registerClipFmt.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(name))))
+	return format
+}
+
+// excludedFromHistory reports whether the open clipboard holds content its
+// owner, typically a password manager, kept out of the clipboard history
+// and clipboard monitors.
+func excludedFromHistory() bool {
+	if clipboardHas(registeredFormat("ExcludeClipboardContentFromMonitorProcessing")) {
+		return true
+	}
+	format := registeredFormat("CanIncludeInClipboardHistory")
+	if !clipboardHas(format) {
+		return false
+	}
+	h, _, _ := getClipboardData.Call(format)
+	if h == 0 {
+		return false
+	}
+	p := win.GlobalLock(win.HGLOBAL(h))
+	if p == nil {
+		return false
+	}
+	defer win.GlobalUnlock(win.HGLOBAL(h))
+	return *(*uint32)(p) == 0
+}
+
+// clipboardHas reports whether the open clipboard offers format.
+func clipboardHas(format uintptr) bool {
+	for f, _, _ := enumClipboardFmt.Call(0); f != 0; f, _, _ = enumClipboardFmt.Call(f) {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// clipboardData is a copy of one clipboard format's contents.
+type clipboardData struct {
+	format uintptr
+	data   []byte
+}
+
+// otherFormats copies every format on the open clipboard except the text
+// ones setClipboardText replaces. Formats held in GDI objects rather than
+// global memory, like bitmaps and metafiles, can't be copied byte for byte
+// and are left out; Windows derives CF_BITMAP again from a kept CF_DIB.
+func otherFormats() []clipboardData {
+	var kept []clipboardData
+	format := uintptr(0)
+	for {
+		format, _, _ = enumClipboardFmt.Call(format)
+		switch {
+		case format == 0:
+			return kept
+		case format == win.CF_TEXT, format == win.CF_UNICODETEXT, format == win.CF_OEMTEXT, format == win.CF_LOCALE:
+			continue
+		case format == win.CF_BITMAP, format == win.CF_PALETTE, format == win.CF_METAFILEPICT, format == win.CF_ENHMETAFILE,
+			format >= win.CF_OWNERDISPLAY && format <= win.CF_DSPENHMETAFILE,
+			format >= win.CF_PRIVATEFIRST && format <= win.CF_GDIOBJLAST:
+			continue
+		}
+		h, _, _ := getClipboardData.Call(format)
+		if h == 0 {
+			continue
+		}
+		p := win.GlobalLock(win.HGLOBAL(h))
+		if p == nil {
+			continue
+		}
+		size, _, _ := globalSize.Call(h)
+		kept = append(kept, clipboardData{format, bytes.Clone(unsafe.Slice((*byte)(p), size))})
+		win.GlobalUnlock(win.HGLOBAL(h))
+	}
+}
+
+// setClipboardBytes copies data into global memory and hands it to the open
+// clipboard under format.
+func setClipboardBytes(format uintptr, data []byte) error {
+	h := win.GlobalAlloc(win.GMEM_MOVEABLE, uintptr(len(data)))
+	if h == 0 {
+		return errors.New("allocate clipboard memory")
+	}
+	p := win.GlobalLock(h)
+	if p == nil {
+		win.GlobalFree(h)
+		return errors.New("lock clipboard memory")
+	}
+	copy(unsafe.Slice((*byte)(p), len(data)), data)
+	win.GlobalUnlock(h)
+	if r, _, err := setClipboardData.Call(format, uintptr(h)); r == 0 {
+		// The clipboard only takes ownership of the memory on success
+		win.GlobalFree(h)
+		return fmt.Errorf("set clipboard data: %w", err)
+	}
+	return nil
+}
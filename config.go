@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Config holds every tunable setting. It is loaded from a JSON file at
+// startup; fields missing from the file keep their defaults, and command
+// line flags override both.
+type Config struct {
+	Hotkey                 string          `json:"hotkey"`
+	Dictionary             string          `json:"dictionary"`
+	MaxDistance            int             `json:"maxDistance"`
+	MinCorrectLength       int             `json:"minCorrectLength"`
+	MaxCandidates          int             `json:"maxCandidates"`
+	Ignore                 []string        `json:"ignore"`
+	SentenceCase           bool            `json:"sentenceCase"`
+	DryRun                 bool            `json:"dryRun"`
+	BKTree                 bool            `json:"bkTree"`
+	AutoCopy               bool            `json:"autoCopy"`
+	AutoPaste              bool            `json:"autoPaste"`
+	Serve                  string          `json:"serve"`
+	LogLevel               string          `json:"logLevel"`
+	Ranking                RankingStrategy `json:"ranking"`
+	AcceptedFile           string          `json:"acceptedFile"`
+	MaxTextLength          int             `json:"maxTextLength"`
+	Notify                 bool            `json:"notify"`
+	SkipPatterns           []string        `json:"skipPatterns"`
+	RejectedFile           string          `json:"rejectedFile"`
+	HistorySize            int             `json:"historySize"`
+	PersistHistory         bool            `json:"persistHistory"`
+	HistoryFile            string          `json:"historyFile"`
+	SplitCompounds         bool            `json:"splitCompounds"`
+	MaxAutoCorrectDistance int             `json:"maxAutoCorrectDistance"`
+	Markdown               bool            `json:"markdown"`
+	MinConfidence          float64         `json:"minConfidence"`
+	StrictDictionary       bool            `json:"strictDictionary"`
+	Watch                  bool            `json:"watch"`
+	WordTimeout            int             `json:"wordTimeout"`
+	ReloadInPlace          bool            `json:"reloadInPlace"`
+	SkipAllCaps            bool            `json:"skipAllCaps"`
+	LastWord               bool            `json:"lastWord"`
+	InPlace                bool            `json:"inPlace"`
+	Annotate               bool            `json:"annotate"`
+	MinFrequency           int             `json:"minFrequency"`
+	WordPunctuation        string          `json:"wordPunctuation"`
+	RemoveRepeats          bool            `json:"removeRepeats"`
+	AllowedRepeats         []string        `json:"allowedRepeats"`
+	Placeholders           []string        `json:"placeholders"`
+	DiffStyle              string          `json:"diffStyle"`
+	StopWordsFile          string          `json:"stopWordsFile"`
+	Marker                 string          `json:"marker"`
+	Suggest                bool            `json:"suggest"`
+	DictionaryDir          string          `json:"dictionaryDir"`
+	DisabledDictionaries   []string        `json:"disabledDictionaries"`
+	Locale                 string          `json:"locale"`
+	PreserveFormats        bool            `json:"preserveFormats"`
+	Strategy               string          `json:"strategy"`
+	RecentWindow           int             `json:"recentWindow"`
+	ReplacementsFile       string          `json:"replacementsFile"`
+	Selection              string          `json:"selection"`
+	ClipboardHistory       bool            `json:"clipboardHistory"`
+	Alignment              string          `json:"alignment"`
+	Warmup                 bool            `json:"warmup"`
+	LengthTolerance        int             `json:"lengthTolerance"`
+	Corrector              string          `json:"corrector"`
+	Homoglyphs             bool            `json:"homoglyphs"`
+	SkipLinePrefixes       []string        `json:"skipLinePrefixes"`
+	Ambiguity              string          `json:"ambiguity"`
+	TieThreshold           float64         `json:"tieThreshold"`
+	OutputTransform        string          `json:"outputTransform"`
+	AppendText             string          `json:"appendText"`
+	Leetspeak              bool            `json:"leetspeak"`
+	MaxCorrectionsPerRun   int             `json:"maxCorrectionsPerRun"`
+}
+
+// RankingStrategy decides when findClosestMatch applies its best candidate.
+type RankingStrategy string
+
+const (
+	// RankAggressive always applies the best candidate.
+	RankAggressive RankingStrategy = "aggressive"
+	// RankConservative only corrects a word when one candidate is strictly
+	// closer than all others, and leaves it unchanged otherwise.
+	RankConservative RankingStrategy = "conservative"
+)
+
+var config = defaultConfig()
+
+// ignoreWords and allowedRepeats are the lowercased sets of config.Ignore
+// and config.AllowedRepeats, and skipPatterns and placeholders hold the
+// compiled config.SkipPatterns and config.Placeholders. All are rebuilt by
+// applyConfig.
+var (
+	ignoreWords    = map[string]bool{}
+	allowedRepeats = map[string]bool{}
+	skipPatterns   []*regexp.Regexp
+	placeholders   []*regexp.Regexp
+)
+
+func defaultConfig() Config {
+	return Config{
+		Hotkey:                 "Ctrl+Alt+S",
+		Dictionary:             "dictionary.txt",
+		MaxDistance:            3,
+		MinCorrectLength:       3,
+		MaxCandidates:          5,
+		LogLevel:               "warn",
+		Ranking:                RankAggressive,
+		AcceptedFile:           "accepted.txt",
+		MaxTextLength:          100000,
+		Notify:                 true,
+		RejectedFile:           "rejected.txt",
+		HistorySize:            100,
+		HistoryFile:            "history.txt",
+		MaxAutoCorrectDistance: 1,
+		WordTimeout:            50,
+		SkipAllCaps:            true,
+		WordPunctuation:        "'",
+		Placeholders:           []string{`\{\{[^{}]*\}\}`},
+		DiffStyle:              "markdown",
+		DictionaryDir:          "dicts",
+		PreserveFormats:        true,
+		Strategy:               "auto",
+		ReplacementsFile:       "replacements.txt",
+		Selection:              "clipboard",
+		ClipboardHistory:       true,
+		Warmup:                 true,
+		Corrector:              "edits",
+		Ambiguity:              "pick",
+		OutputTransform:        "none",
+	}
+}
+
+// loadConfig reads the JSON file at path over the current config. A missing
+// file is not an error; the defaults are used as they are.
+func loadConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		infof("No config file at %s, using defaults", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err == nil {
+		known := configKeys()
+		for key := range keys {
+			if !known[key] {
+				warnf("Unknown config key %q in %s", key, path)
+			}
+		}
+	}
+	infof("Loaded config from %s", path)
+	return nil
+}
+
+// configKeys returns the JSON key of every Config field.
+func configKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		keys[name] = true
+	}
+	return keys
+}
+
+// applyConfig refreshes the values derived from config.
+func applyConfig() {
+	setLocale(config.Locale)
+
+	// Negative counts would panic when slicing candidate lists or make no
+	// sense; 0 keeps its meaning of "off" or "no limit" where it has one
+	atLeast("maxCandidates", &config.MaxCandidates, 1)
+	atLeast("maxDistance", &config.MaxDistance, 0)
+	atLeast("maxAutoCorrectDistance", &config.MaxAutoCorrectDistance, 0)
+	atLeast("minCorrectLength", &config.MinCorrectLength, 0)
+	atLeast("maxTextLength", &config.MaxTextLength, 0)
+	atLeast("historySize", &config.HistorySize, 0)
+	atLeast("wordTimeout", &config.WordTimeout, 0)
+	atLeast("minFrequency", &config.MinFrequency, 0)
+	atLeast("recentWindow", &config.RecentWindow, 0)
+	atLeast("lengthTolerance", &config.LengthTolerance, 0)
+	atLeast("maxCorrectionsPerRun", &config.MaxCorrectionsPerRun, 0)
+	if config.MinConfidence < 0 {
+		warnf("minConfidence must be at least 0, using 0")
+		config.MinConfidence = 0
+	}
+	if config.TieThreshold < 0 || config.TieThreshold > 1 {
+		warnf("tieThreshold must be between 0 and 1, using 0")
+		config.TieThreshold = 0
+	}
+
+	switch config.Ranking {
+	case RankAggressive, RankConservative:
+	default:
+		warnf("Unknown ranking strategy %q, using %q", config.Ranking, RankAggressive)
+		config.Ranking = RankAggressive
+	}
+
+	switch config.Selection {
+	case "clipboard", "primary":
+	default:
+		warnf("Unknown selection %q, using %q", config.Selection, "clipboard")
+		config.Selection = "clipboard"
+	}
+
+	if c, ok := correctors[config.Corrector]; ok {
+		corrector = c
+	} else {
+		warnf("Unknown corrector %q, using %q (have %s)", config.Corrector, "edits", strings.Join(correctorNames(), ", "))
+		config.Corrector, corrector = "edits", correctors["edits"]
+	}
+
+	switch config.Ambiguity {
+	case "pick", "leave", "mark":
+	default:
+		warnf("Unknown ambiguity policy %q, using %q", config.Ambiguity, "pick")
+		config.Ambiguity = "pick"
+	}
+
+	switch config.Alignment {
+	case "", "pad", "skip":
+	default:
+		warnf("Unknown alignment %q, keeping word lengths free", config.Alignment)
+		config.Alignment = ""
+	}
+
+	switch config.Strategy {
+	case "auto", "edits", "scan":
+	default:
+		warnf("Unknown strategy %q, using %q", config.Strategy, "auto")
+		config.Strategy = "auto"
+	}
+
+	if _, ok := outputTransforms[config.OutputTransform]; !ok {
+		warnf("Unknown output transform %q, using %q", config.OutputTransform, "none")
+		config.OutputTransform = "none"
+	}
+
+	if _, ok := diffMarkers[config.DiffStyle]; !ok {
+		warnf("Unknown diff style %q, using %q", config.DiffStyle, "markdown")
+		config.DiffStyle = "markdown"
+	}
+
+	ignoreWords = map[string]bool{}
+	for _, word := range config.Ignore {
+		ignoreWords[toLower(word)] = true
+	}
+
+	allowedRepeats = map[string]bool{}
+	for _, word := range config.AllowedRepeats {
+		allowedRepeats[toLower(word)] = true
+	}
+
+	skipPatterns = nil
+	for _, pattern := range config.SkipPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errorf("Invalid skip pattern %q: %v", pattern, err)
+			continue
+		}
+		skipPatterns = append(skipPatterns, re)
+	}
+
+	placeholders = nil
+	for _, pattern := range config.Placeholders {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errorf("Invalid placeholder pattern %q: %v", pattern, err)
+			continue
+		}
+		placeholders = append(placeholders, re)
+	}
+}
+
+// atLeast raises the setting *value, named key in the config file, to
+// lowest with a warning if it is lower.
+func atLeast(key string, value *int, lowest int) {
+	if *value < lowest {
+		warnf("%s must be at least %d, using %d", key, lowest, lowest)
+		*value = lowest
+	}
+}
+
+// defaultStopWords are short function words that are valid but too easy to
+// reach from unrelated typos to be offered as corrections.
+var defaultStopWords = []string{
+	"a", "an", "as", "at", "be", "by", "do", "i", "if", "in", "is", "it",
+	"me", "my", "no", "of", "on", "or", "so", "to", "up", "us", "we",
+}
+
+// stopWords is the set of words never corrected and never suggested. It
+// holds defaultStopWords unless loadStopWords reads config.StopWordsFile.
+var stopWords = map[string]bool{}
+
+// loadStopWords fills stopWords from path, one word per line with "#"
+// comments, or from defaultStopWords when path is empty.
+func loadStopWords(path string) error {
+	words := defaultStopWords
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		words = nil
+		err = readLines(file, func(line string) {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				words = append(words, line)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		infof("Loaded %d stop word(s) from %s", len(words), path)
+	}
+
+	stopWords = map[string]bool{}
+	for _, word := range words {
+		stopWords[toLower(word)] = true
+	}
+	return nil
+}
+
+// Hotkey is a parsed RegisterHotKey modifier set and virtual key code.
+type Hotkey struct {
+	mods uintptr
+	vk   uintptr
+}
+
+// parseHotkey parses a shortcut such as "Ctrl+Alt+S" or "Ctrl+Shift+F8".
+func parseHotkey(s string) (Hotkey, error) {
+	var hk Hotkey
+	parts := strings.Split(s, "+")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if i < len(parts)-1 {
+			switch strings.ToLower(part) {
+			case "ctrl", "control":
+				hk.mods |= MOD_CTRL
+			case "alt":
+				hk.mods |= MOD_ALT
+			case "shift":
+				hk.mods |= MOD_SHIFT
+			case "win":
+				hk.mods |= MOD_WIN
+			default:
+				return hk, fmt.Errorf("unknown modifier %q in hotkey %q", part, s)
+			}
+			continue
+		}
+
+		key := strings.ToUpper(part)
+		switch {
+		case len(key) == 1 && (key[0] >= 'A' && key[0] <= 'Z' || key[0] >= '0' && key[0] <= '9'):
+			hk.vk = uintptr(key[0]) // letters and digits use their ASCII codes
+		case len(key) > 1 && key[0] == 'F':
+			var n int
+			if _, err := fmt.Sscanf(key[1:], "%d", &n); err != nil || n < 1 || n > 24 {
+				return hk, fmt.Errorf("unknown key %q in hotkey %q", part, s)
+			}
+			hk.vk = uintptr(VK_F1 + n - 1)
+		default:
+			return hk, fmt.Errorf("unknown key %q in hotkey %q", part, s)
+		}
+	}
+	if hk.mods == 0 {
+		return hk, fmt.Errorf("hotkey %q needs at least one modifier", s)
+	}
+	return hk, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestApplyConfigClampsNumbers(t *testing.T) {
+	useWords(t, "hello", "help")
+	config.MaxCandidates = -1
+	config.MaxDistance = -2
+	config.WordTimeout = -5
+	config.TieThreshold = 2
+	applyConfig()
+
+	if config.MaxCandidates != 1 || config.MaxDistance != 0 || config.WordTimeout != 0 || config.TieThreshold != 0 {
+		t.Errorf("applyConfig left maxCandidates %d, maxDistance %d, wordTimeout %d, tieThreshold %v",
+			config.MaxCandidates, config.MaxDistance, config.WordTimeout, config.TieThreshold)
+	}
+}
+
+func TestCheckNegativeLimit(t *testing.T) {
+	useWords(t, "hello", "help")
+	ok, suggestions := Check("helo", -1)
+	if ok || len(suggestions) != 0 {
+		t.Errorf("Check(helo, -1) = %v, %v, want false and no suggestions", ok, suggestions)
+	}
+}
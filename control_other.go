@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// correctFocusedControl is only supported on Windows; elsewhere the
+// clipboard is always used.
+func correctFocusedControl() bool {
+	return false
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+var getGUIThreadInfo = user32.NewProc("GetGUIThreadInfo")
+
+// guiThreadInfo mirrors the Win32 GUITHREADINFO structure.
+type guiThreadInfo struct {
+	cbSize        uint32
+	flags         uint32
+	hwndActive    win.HWND
+	hwndFocus     win.HWND
+	hwndCapture   win.HWND
+	hwndMenuOwner win.HWND
+	hwndMoveSize  win.HWND
+	hwndCaret     win.HWND
+	rcCaret       win.RECT
+}
+
+// editClasses are the window classes of the standard edit controls whose
+// text can be read and replaced with window messages.
+var editClasses = []string{"edit", "richedit20w", "richedit50w", "richedit60w"}
+
+// correctFocusedControl corrects the text of the focused edit control in
+// place, keeping its caret and undo history. It reports false when the
+// focused window isn't a standard edit control, in which case the caller
+// falls back to the clipboard.
+func correctFocusedControl() bool {
+	hwnd := focusedWindow()
+	if hwnd == 0 || !isEditControl(hwnd) {
+		debugf("Focused control is not an edit control, using the clipboard")
+		return false
+	}
+
+	n := win.SendMessage(hwnd, win.WM_GETTEXTLENGTH, 0, 0)
+	units := make([]uint16, n+1)
+	n = win.SendMessage(hwnd, win.WM_GETTEXT, uintptr(len(units)), uintptr(unsafe.Pointer(&units[0])))
+	text := string(utf16.Decode(units[:n]))
+	if text == "" {
+		return true
+	}
+
+	// The selection comes back packed into the result; it is only reliable
+	// for controls under 64K characters, where it is what we need anyway
+	sel := win.SendMessage(hwnd, win.EM_GETSEL, 0, 0)
+	selStart, selEnd := int(win.LOWORD(uint32(sel))), int(win.HIWORD(uint32(sel)))
+
+	corrected, changes := correctText(text)
+	if len(changes) == 0 {
+		return true
+	}
+	shifts := changes
+	if config.Suggest {
+		// The text stays as it is, so the selection doesn't move
+		corrected, shifts = withSuggestions(text, changes), nil
+	}
+	replacement, err := syscall.UTF16PtrFromString(corrected)
+	if err != nil {
+		errorf("Failed to correct control text: %v", err)
+		return true
+	}
+	win.SendMessage(hwnd, win.EM_SETSEL, 0, ^uintptr(0))
+	win.SendMessage(hwnd, win.EM_REPLACESEL, win.TRUE, uintptr(unsafe.Pointer(replacement)))
+	start := shiftOffset(text, corrected, shifts, selStart)
+	end := shiftOffset(text, corrected, shifts, selEnd)
+	win.SendMessage(hwnd, win.EM_SETSEL, uintptr(start), uintptr(end))
+
+	infof("Corrected %d word(s) in place", len(changes))
+	lastRun.Lock()
+	lastRun.text, lastRun.changes = "", nil // undo goes through the control's own Ctrl+Z
+	lastRun.Unlock()
+	recordHistory(changes)
+	rememberCorrected(changes)
+	if config.Notify {
+		notify("Spell Checker", summarizeChanges(changes))
+	}
+	return true
+}
+
+// focusedWindow returns the control with keyboard focus in the foreground
+// window, which may belong to another process.
+func focusedWindow() win.HWND {
+	fg := win.GetForegroundWindow()
+	if fg == 0 {
+		return 0
+	}
+	info := guiThreadInfo{}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+	thread := win.GetWindowThreadProcessId(fg, nil)
+	if r, _, _ := getGUIThreadInfo.Call(uintptr(thread), uintptr(unsafe.Pointer(&info))); r == 0 {
+		return 0
+	}
+	return info.hwndFocus
+}
+
+// isEditControl reports whether hwnd is one of editClasses.
+func isEditControl(hwnd win.HWND) bool {
+	buf := make([]uint16, 64)
+	n, err := win.GetClassName(hwnd, &buf[0], len(buf))
+	if err != nil || n == 0 {
+		return false
+	}
+	class := strings.ToLower(syscall.UTF16ToString(buf[:n]))
+	for _, c := range editClasses {
+		if class == c {
+			return true
+		}
+	}
+	return false
+}
+
+// shiftOffset maps a UTF-16 offset in text to the same place in corrected,
+// moving it by the length difference of every change before it.
+func shiftOffset(text, corrected string, changes []Change, offset int) int {
+	pos := byteOffset(text, offset)
+	for _, c := range changes {
+		if c.Offset+len(c.Original) > pos {
+			break
+		}
+		pos += len(c.Corrected) - len(c.Original)
+	}
+	return len(utf16.Encode([]rune(corrected[:min(max(pos, 0), len(corrected))])))
+}
+
+// byteOffset converts a UTF-16 offset into a byte offset in s.
+func byteOffset(s string, units int) int {
+	for i, r := range s {
+		if units <= 0 {
+			return i
+		}
+		units -= len(utf16.Encode([]rune{r}))
+	}
+	return len(s)
+}
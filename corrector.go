@@ -0,0 +1,74 @@
+package main
+
+import "sort"
+
+// Corrector is a backend that corrects single words. correctWord hands it
+// every lowercase word that is long enough, not in the dictionary and not
+// skipped, ignored, replaced or a known contraction, so a backend only
+// has to guess. Correct is called with dictionaryMu held for reading,
+// possibly from several goroutines at once, and returns the correction
+// and whether it found one.
+type Corrector interface {
+	Correct(word string) (string, bool)
+}
+
+// scoredCorrector is a Corrector that also rates its corrections between 0
+// and 1, like findClosestMatch. Corrections from other backends count as
+// certain.
+type scoredCorrector interface {
+	Corrector
+	CorrectScored(word string) (string, float64)
+}
+
+// editCorrector is the default backend: the edit distance search of
+// findClosestMatch.
+type editCorrector struct{}
+
+func (editCorrector) Correct(word string) (string, bool) {
+	corrected, _ := findClosestMatch(word)
+	return corrected, corrected != word
+}
+
+func (editCorrector) CorrectScored(word string) (string, float64) {
+	return findClosestMatch(word)
+}
+
+// correctors are the backends config.Corrector can name. Alternative ones are
+// added with registerCorrector.
+var correctors = map[string]Corrector{
+	"edits": editCorrector{},
+}
+
+// corrector is the backend in use, set by applyConfig from config.Corrector.
+var corrector Corrector = editCorrector{}
+
+// registerCorrector makes c available as config.Corrector name. It must be
+// called before the config is applied, typically from an init function.
+func registerCorrector(name string, c Corrector) {
+	correctors[name] = c
+}
+
+// correctorNames returns the registered backend names, sorted.
+func correctorNames() []string {
+	names := make([]string, 0, len(correctors))
+	for name := range correctors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// correctWith corrects word with c, returning word itself when it is in the
+// dictionary or c finds nothing.
+func correctWith(c Corrector, word string) (string, float64) {
+	if dictionary.search(word) {
+		return word, 1
+	}
+	if s, ok := c.(scoredCorrector); ok {
+		return s.CorrectScored(word)
+	}
+	if corrected, ok := c.Correct(word); ok {
+		return corrected, 1
+	}
+	return word, 1
+}
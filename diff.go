@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffMarkers maps each config.DiffStyle to the format markChanges writes
+// a change in, with the original word and its correction as arguments.
+var diffMarkers = map[string]func(original, corrected string) string{
+	"markdown": func(original, corrected string) string {
+		return "~~" + original + "~~" + corrected
+	},
+	"brackets": func(original, corrected string) string {
+		return "[" + original + " → " + corrected + "]"
+	},
+}
+
+// markChanges renders text with every change in changes shown inline as an
+// old/new pair, in the style set by config.DiffStyle, so a whole dry run can
+// be reviewed at a glance. changes must come from correcting text.
+func markChanges(text string, changes []Change) string {
+	mark := diffMarkers[config.DiffStyle]
+	var b strings.Builder
+	last := 0
+	for _, c := range changes {
+		b.WriteString(text[last:c.Offset])
+		last = c.Offset + len(c.Original)
+
+		// Keep punctuation both words share outside the markers
+		prefix, original, suffix := splitPunctuation(c.Original)
+		corrected, hasPrefix := strings.CutPrefix(c.Corrected, prefix)
+		corrected, hasSuffix := strings.CutSuffix(corrected, suffix)
+		if !hasPrefix || !hasSuffix {
+			b.WriteString(mark(c.Original, c.Corrected))
+			continue
+		}
+		b.WriteString(prefix + mark(original, corrected) + suffix)
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// withSuggestions returns text unchanged but followed by a footnote listing
+// changes with their line numbers, "Suggestions: teh → the (line 1), ...",
+// for when the text itself must not be altered. changes must come from
+// correcting text.
+func withSuggestions(text string, changes []Change) string {
+	if len(changes) == 0 {
+		return text
+	}
+	newline := "\n"
+	if strings.Contains(text, "\r\n") {
+		newline = "\r\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		b.WriteString(newline)
+	}
+	b.WriteString(newline + "Suggestions: ")
+	line, last := 1, 0
+	for i, c := range changes {
+		line += strings.Count(text[last:c.Offset], "\n")
+		last = c.Offset
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		_, original, _ := splitPunctuation(c.Original)
+		_, corrected, _ := splitPunctuation(c.Corrected)
+		fmt.Fprintf(&b, "%s → %s (line %d)", original, corrected, line)
+	}
+	if strings.HasSuffix(text, "\n") {
+		b.WriteString(newline)
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package main
+
+// levenshteinDistance returns the number of single-rune insertions,
+// deletions and substitutions needed to turn s into t.
+func levenshteinDistance(s, t string) int {
+	a, b := []rune(s), []rune(t)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// DistanceWithin returns the Levenshtein distance between s and t if it is
+// at most maxDist, and whether it is. Only the diagonal band of width
+// maxDist is computed and the comparison stops as soon as every cell in a
+// row exceeds maxDist, so checking against a small bound is much cheaper
+// than levenshteinDistance for dissimilar words. When the distance is out
+// of bounds the returned value is maxDist+1.
+func DistanceWithin(s, t string, maxDist int) (int, bool) {
+	a, b := []rune(s), []rune(t)
+	limit := maxDist + 1
+	if maxDist < 0 || len(a)-len(b) > maxDist || len(b)-len(a) > maxDist {
+		return limit, false
+	}
+
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = min(j, limit)
+	}
+	for i := 1; i <= len(a); i++ {
+		lo, hi := max(1, i-maxDist), min(len(b), i+maxDist)
+		curr[0] = min(i, limit)
+		if lo > 1 {
+			curr[lo-1] = limit // left of the band
+		}
+		rowMin := limit
+		if lo == 1 {
+			rowMin = curr[0]
+		}
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost, limit)
+			rowMin = min(rowMin, curr[j])
+		}
+		if hi < len(b) {
+			curr[hi+1] = limit // right of the band
+		}
+		if rowMin > maxDist {
+			return limit, false
+		}
+		prev, curr = curr, prev
+	}
+	d := prev[len(b)]
+	return d, d <= maxDist
+}
+
+// alignmentWithin is DistanceWithin for rune slices, except that swapping
+// two adjacent runes counts as a single edit, as it does in edits. This is
+// the optimal string alignment distance. The comparison stops once two
+// consecutive rows exceed maxDist, since no later cell can come back under.
+func alignmentWithin(a, b []rune, maxDist int) (int, bool) {
+	limit := maxDist + 1
+	if maxDist < 0 || len(a)-len(b) > maxDist || len(b)-len(a) > maxDist {
+		return limit, false
+	}
+
+	before := make([]int, len(b)+1) // row i-2, for transpositions
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	prevMin := 0
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = min(curr[j], before[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > maxDist && prevMin > maxDist {
+			return limit, false
+		}
+		prevMin = rowMin
+		before, prev, curr = prev, curr, before
+	}
+	d := prev[len(b)]
+	if d > maxDist {
+		return limit, false
+	}
+	return d, true
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyEntry is one correction made during the session.
+type historyEntry struct {
+	Time      time.Time
+	Original  string
+	Corrected string
+}
+
+// history keeps the last config.HistorySize corrections in a ring buffer.
+// Nothing leaves the machine: it is only written to a local file when the
+// user views it, or on exit when config.PersistHistory is set.
+var history struct {
+	sync.Mutex
+	entries []historyEntry
+	next    int // slot the next entry goes to once the buffer is full
+}
+
+// recent maps the lowercase words corrected within the last
+// config.RecentWindow seconds to when that happened. If such a word shows
+// up again, the user most likely typed it back on purpose, so it is left
+// alone instead of flipping back and forth on every press of the hotkey.
+var recent struct {
+	sync.Mutex
+	words map[string]time.Time
+}
+
+// rememberCorrected records the words changes corrected, forgetting those
+// older than config.RecentWindow.
+func rememberCorrected(changes []Change) {
+	if config.RecentWindow <= 0 {
+		return
+	}
+	now := time.Now()
+	recent.Lock()
+	defer recent.Unlock()
+	if recent.words == nil {
+		recent.words = map[string]time.Time{}
+	}
+	for word, at := range recent.words {
+		if now.Sub(at) > recentWindow() {
+			delete(recent.words, word)
+		}
+	}
+	for _, c := range changes {
+		_, original, _ := splitPunctuation(c.Original)
+		if _, corrected, _ := splitPunctuation(c.Corrected); corrected != "" {
+			recent.words[toLower(original)] = now // not a removed repeat
+		}
+	}
+}
+
+// correctedRecently reports whether word was corrected within the last
+// config.RecentWindow seconds.
+func correctedRecently(word string) bool {
+	if config.RecentWindow <= 0 {
+		return false
+	}
+	_, core, _ := splitPunctuation(word)
+	recent.Lock()
+	defer recent.Unlock()
+	at, ok := recent.words[toLower(core)]
+	return ok && time.Since(at) <= recentWindow()
+}
+
+// recentWindow returns config.RecentWindow as a duration.
+func recentWindow() time.Duration {
+	return time.Duration(config.RecentWindow) * time.Second
+}
+
+// recordHistory adds the changes of one correction run to the history.
+func recordHistory(changes []Change) {
+	if config.HistorySize <= 0 {
+		return
+	}
+	now := time.Now()
+	history.Lock()
+	defer history.Unlock()
+	for _, c := range changes {
+		addHistory(historyEntry{Time: now, Original: c.Original, Corrected: c.Corrected})
+	}
+}
+
+// addHistory stores e, overwriting the oldest entry once the buffer is full.
+// The caller must hold the history lock.
+func addHistory(e historyEntry) {
+	if len(history.entries) < config.HistorySize {
+		history.entries = append(history.entries, e)
+		return
+	}
+	history.entries[history.next] = e
+	history.next = (history.next + 1) % len(history.entries)
+}
+
+// historyEntries returns the history from oldest to newest.
+func historyEntries() []historyEntry {
+	history.Lock()
+	defer history.Unlock()
+	entries := make([]historyEntry, 0, len(history.entries))
+	entries = append(entries, history.entries[history.next:]...)
+	return append(entries, history.entries[:history.next]...)
+}
+
+// formatHistory renders entries as tab-separated "time original corrected"
+// lines, the format loadHistory reads back.
+func formatHistory(entries []historyEntry) string {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.Original, e.Corrected)
+	}
+	return b.String()
+}
+
+// viewHistory writes the history to a temporary file and opens it in the
+// default text viewer.
+func viewHistory() {
+	entries := historyEntries()
+	text := formatHistory(entries)
+	if len(entries) == 0 {
+		text = "No corrections yet.\n"
+	}
+	path := filepath.Join(os.TempDir(), "spell-checker-history.txt")
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		errorf("Failed to write history: %v", err)
+		return
+	}
+	openFile(path)
+}
+
+// loadHistory reads a history saved by saveHistory. A missing file just
+// means no history was kept yet.
+func loadHistory(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	history.Lock()
+	defer history.Unlock()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, fields[0])
+		if err != nil {
+			continue
+		}
+		addHistory(historyEntry{Time: t, Original: fields[1], Corrected: fields[2]})
+	}
+	return scanner.Err()
+}
+
+// saveHistory writes the history to path so the next session starts with it.
+func saveHistory(path string) error {
+	return os.WriteFile(path, []byte(formatHistory(historyEntries())), 0o600)
+}
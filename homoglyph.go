@@ -0,0 +1,42 @@
+package main
+
+import "strings"
+
+// confusables maps Cyrillic and Greek letters that look like Latin ones to
+// the Latin letter they are usually mistaken for.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y',
+	'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	'һ': 'h', 'ӏ': 'l',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+	'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'У': 'Y', 'І': 'I', 'Ј': 'J',
+	'Ѕ': 'S', 'Ԛ': 'Q', 'Ԝ': 'W',
+	// Greek
+	'α': 'a', 'ο': 'o', 'ν': 'v', 'ρ': 'p', 'ι': 'i', 'κ': 'k',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Χ': 'X', 'Υ': 'Y',
+}
+
+// unconfuse replaces the confusables in word with their Latin look-alikes,
+// reporting whether it changed anything. Only words that also contain a
+// Latin letter are touched, so text written in Cyrillic or Greek is left
+// alone.
+func unconfuse(word string) (string, bool) {
+	if !strings.ContainsFunc(word, isLatinLetter) {
+		return word, false
+	}
+	changed := false
+	fixed := strings.Map(func(r rune) rune {
+		if latin, ok := confusables[r]; ok {
+			changed = true
+			return latin
+		}
+		return r
+	}, word)
+	return fixed, changed
+}
+
+func isLatinLetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
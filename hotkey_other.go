@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// listenHotkey is a no-op outside Windows; use the tray menu instead.
+func listenHotkey() {
+	warnf("Global hotkey %s is only supported on Windows", config.Hotkey)
+}
+
+func stopHotkey() {}
+
+// checkHotkey reports errors.ErrUnsupported, since there is no global hotkey
+// to register outside Windows.
+func checkHotkey() error {
+	return errors.ErrUnsupported
+}
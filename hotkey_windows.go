@@ -0,0 +1,85 @@
+package main
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+var (
+	registerHotKey    = user32.NewProc("RegisterHotKey")
+	unregisterHotKey  = user32.NewProc("UnregisterHotKey")
+	getMessage        = user32.NewProc("GetMessageW")
+	peekMessage       = user32.NewProc("PeekMessageW")
+	postThreadMessage = user32.NewProc("PostThreadMessageW")
+)
+
+const HOTKEY_ID = 1
+
+// hotkeyThreadID is the OS thread running the hotkey message loop, used by
+// stopHotkey to post WM_QUIT to it. hotkeyDone is closed once the loop has
+// unregistered the hotkey and returned.
+var (
+	hotkeyThreadID uint32
+	hotkeyDone     = make(chan struct{})
+)
+
+// stopHotkey stops the hotkey loop so it can unregister the hotkey from its
+// own thread; RegisterHotKey bindings belong to the registering thread.
+func stopHotkey() {
+	if id := atomic.LoadUint32(&hotkeyThreadID); id != 0 {
+		postThreadMessage.Call(uintptr(id), win.WM_QUIT, 0, 0)
+		select {
+		case <-hotkeyDone:
+		case <-time.After(time.Second):
+			warnf("Timed out waiting for hotkey loop to exit")
+		}
+	}
+}
+
+// checkHotkey registers the configured hotkey and unregisters it right away,
+// to tell whether it is valid and not taken by another program.
+func checkHotkey() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	r, _, err := registerHotKey.Call(0, HOTKEY_ID, hotkey.mods, hotkey.vk)
+	if r == 0 {
+		return err
+	}
+	unregisterHotKey.Call(0, HOTKEY_ID)
+	return nil
+}
+
+// listenHotkey registers the configured hotkey and runs checkSpelling each
+// time it is pressed, until stopHotkey posts WM_QUIT to this thread.
+func listenHotkey() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(hotkeyDone)
+
+	// Make sure the thread has a message queue before publishing its ID
+	var msg win.MSG
+	peekMessage.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0, win.PM_NOREMOVE)
+	atomic.StoreUint32(&hotkeyThreadID, win.GetCurrentThreadId())
+
+	r, _, err := registerHotKey.Call(0, HOTKEY_ID, hotkey.mods, hotkey.vk)
+	if r == 0 {
+		errorf("Failed to register hotkey: %v", err)
+		return
+	}
+	defer unregisterHotKey.Call(0, HOTKEY_ID)
+
+	for {
+		r, _, _ := getMessage.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+		if int32(r) <= 0 {
+			return // WM_QUIT or error
+		}
+		if msg.Message == win.WM_HOTKEY && msg.WParam == HOTKEY_ID {
+			checkSpelling()
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// affixRule is one line of a Hunspell PFX or SFX class: strip is removed
+// from the stem, add is put in its place, and the rule only applies when
+// the stem matches condition.
+type affixRule struct {
+	strip     string
+	add       string
+	condition *regexp.Regexp
+}
+
+// affixClass is a PFX or SFX block of an .aff file.
+type affixClass struct {
+	prefix bool
+	cross  bool // may combine with an affix of the other kind
+	rules  []affixRule
+}
+
+// affixFile holds the subset of a Hunspell .aff file readHunspell uses.
+type affixFile struct {
+	flagType string // "", "long", "num" or "UTF-8"
+	classes  map[string]*affixClass
+}
+
+// readHunspell reads a Hunspell .dic word list from r and expands every stem
+// with the prefix and suffix rules of the .aff file at affPath, calling add
+// for the stem and each derived word. Only plain PFX/SFX rules are
+// supported; flags for compounding, casing and the like are skipped with a
+// warning.
+func readHunspell(r io.Reader, affPath string, add func(word string, freq int)) error {
+	aff, err := readAffixFile(affPath)
+	if err != nil {
+		return err
+	}
+
+	unsupported := map[string]bool{}
+	first := true
+	err = readLines(r, func(line string) {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+		if first {
+			first = false
+			if _, err := strconv.Atoi(line); err == nil {
+				return // the word count
+			}
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			return
+		}
+		// Morphological fields follow the word after whitespace
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			line = line[:i]
+		}
+		stem, flags, _ := strings.Cut(line, "/")
+		add(stem, 0)
+
+		var prefixes, suffixes []*affixClass
+		for _, flag := range aff.splitFlags(flags) {
+			class, ok := aff.classes[flag]
+			switch {
+			case !ok:
+				unsupported[flag] = true
+			case class.prefix:
+				prefixes = append(prefixes, class)
+			default:
+				suffixes = append(suffixes, class)
+			}
+		}
+		for _, sfx := range suffixes {
+			for _, word := range sfx.apply(stem) {
+				add(word, 0)
+				if !sfx.cross {
+					continue
+				}
+				for _, pfx := range prefixes {
+					if pfx.cross {
+						for _, w := range pfx.apply(word) {
+							add(w, 0)
+						}
+					}
+				}
+			}
+		}
+		for _, pfx := range prefixes {
+			for _, word := range pfx.apply(stem) {
+				add(word, 0)
+			}
+		}
+	})
+	if len(unsupported) > 0 {
+		flags := make([]string, 0, len(unsupported))
+		for flag := range unsupported {
+			flags = append(flags, flag)
+		}
+		sort.Strings(flags)
+		warnf("Skipped unsupported Hunspell flags: %s", strings.Join(flags, " "))
+	}
+	return err
+}
+
+// readAffixFile parses the FLAG, PFX and SFX lines of a Hunspell .aff file.
+// A missing file leaves every stem unexpanded.
+func readAffixFile(path string) (*affixFile, error) {
+	aff := &affixFile{classes: map[string]*affixClass{}}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		warnf("No affix file %s, loading stems only", path)
+		return aff, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	err = readLines(file, func(line string) {
+		fields := strings.Fields(strings.TrimPrefix(line, "\ufeff"))
+		if len(fields) < 2 {
+			return
+		}
+		switch fields[0] {
+		case "SET":
+			if !strings.EqualFold(fields[1], "UTF-8") {
+				warnf("Affix file %s uses %s; only UTF-8 is supported", path, fields[1])
+			}
+		case "FLAG":
+			aff.flagType = fields[1]
+		case "PFX", "SFX":
+			class := aff.classes[fields[1]]
+			if class == nil && len(fields) >= 3 {
+				// Header: PFX flag cross-product count
+				aff.classes[fields[1]] = &affixClass{prefix: fields[0] == "PFX", cross: fields[2] == "Y"}
+				return
+			}
+			if class == nil || len(fields) < 4 {
+				return
+			}
+			rule, err := parseAffixRule(class.prefix, fields[2], fields[3], fields)
+			if err != nil {
+				warnf("Skipping affix rule %q: %v", line, err)
+				return
+			}
+			class.rules = append(class.rules, rule)
+		}
+	})
+	return aff, err
+}
+
+// parseAffixRule builds a rule from the strip, add and optional condition
+// fields of a PFX or SFX line.
+func parseAffixRule(prefix bool, strip, add string, fields []string) (affixRule, error) {
+	if strip == "0" {
+		strip = ""
+	}
+	if affix, _, ok := strings.Cut(add, "/"); ok {
+		add = affix // continuation classes are not supported
+	}
+	if add == "0" {
+		add = ""
+	}
+	condition := "."
+	if len(fields) > 4 {
+		condition = fields[4]
+	}
+	pattern := "(?:" + condition + ")$"
+	if prefix {
+		pattern = "^(?:" + condition + ")"
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return affixRule{}, fmt.Errorf("condition %q: %w", condition, err)
+	}
+	return affixRule{strip: strip, add: add, condition: re}, nil
+}
+
+// apply returns every word the class derives from stem.
+func (c *affixClass) apply(stem string) []string {
+	var words []string
+	for _, rule := range c.rules {
+		if !rule.condition.MatchString(stem) {
+			continue
+		}
+		if c.prefix {
+			if rest, ok := strings.CutPrefix(stem, rule.strip); ok {
+				words = append(words, rule.add+rest)
+			}
+		} else if rest, ok := strings.CutSuffix(stem, rule.strip); ok {
+			words = append(words, rest+rule.add)
+		}
+	}
+	return words
+}
+
+// splitFlags splits the flags after a stem's slash according to the FLAG
+// type: one character per flag by default, two with "long", and comma
+// separated numbers with "num".
+func (a *affixFile) splitFlags(flags string) []string {
+	var out []string
+	switch a.flagType {
+	case "long":
+		for i := 0; i+1 < len(flags); i += 2 {
+			out = append(out, flags[i:i+2])
+		}
+	case "num":
+		out = strings.Split(flags, ",")
+	default:
+		for len(flags) > 0 {
+			_, size := utf8.DecodeRuneInString(flags)
+			out = append(out, flags[:size])
+			flags = flags[size:]
+		}
+	}
+	return out
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// Tray icons are 32x32 PNG-compressed ICO files, embedded so the app works
+// without any files next to it. Each can be replaced by putting an icon
+// file of the same name next to the executable.
+const (
+	iconFile         = "icon.ico"
+	busyIconFile     = "icon_busy.ico"
+	disabledIconFile = "icon_disabled.ico"
+)
+
+// getIcon returns the default tray icon.
+func getIcon() []byte {
+	return loadIcon(iconFile, defaultIcon)
+}
+
+// getBusyIcon returns the icon shown while checkSpelling is running.
+func getBusyIcon() []byte {
+	return loadIcon(busyIconFile, busyIcon)
+}
+
+// getDisabledIcon returns the icon shown while correction is turned off.
+func getDisabledIcon() []byte {
+	return loadIcon(disabledIconFile, disabledIcon)
+}
+
+// loadIcon reads the named icon file from the executable's directory,
+// falling back to the embedded bytes when it is missing or isn't an ICO.
+func loadIcon(name string, embedded []byte) []byte {
+	exe, err := os.Executable()
+	if err != nil {
+		return embedded
+	}
+	path := filepath.Join(filepath.Dir(exe), name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return embedded
+	}
+	if !isICO(data) {
+		warnf("%s is not a valid .ico file, using the built-in icon", path)
+		return embedded
+	}
+	return data
+}
+
+// isICO checks for the ICONDIR header: reserved 0, type 1 (icon) and at
+// least one directory entry.
+func isICO(data []byte) bool {
+	const headerSize, entrySize = 6, 16
+	if len(data) < headerSize+entrySize || !bytes.HasPrefix(data, []byte{0, 0, 1, 0}) {
+		return false
+	}
+	count := int(data[4]) | int(data[5])<<8
+	return count > 0 && len(data) >= headerSize+count*entrySize
+}
+
+// defaultIcon is a blue check mark.
+var defaultIcon = []byte{
+	0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x20, 0x20, 0x00, 0x00, 0x01, 0x00,
+	0x20, 0x00, 0xf2, 0x01, 0x00, 0x00, 0x16, 0x00, 0x00, 0x00, 0x89, 0x50,
+	0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48,
+	0x44, 0x52, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x20, 0x08, 0x06,
+	0x00, 0x00, 0x00, 0x73, 0x7a, 0x7a, 0xf4, 0x00, 0x00, 0x01, 0xb9, 0x49,
+	0x44, 0x41, 0x54, 0x78, 0xda, 0x63, 0x50, 0xcc, 0xbb, 0xc3, 0x30, 0x90,
+	0x98, 0x54, 0x0d, 0x81, 0x40, 0x3c, 0x05, 0x88, 0x4f, 0x00, 0xf1, 0x6b,
+	0x20, 0xfe, 0x03, 0xc5, 0xaf, 0xa1, 0x62, 0x53, 0xa0, 0x6a, 0xa8, 0xea,
+	0x00, 0x3e, 0x20, 0x6e, 0x86, 0x5a, 0xf2, 0x9f, 0x48, 0xfc, 0x1a, 0xaa,
+	0x87, 0x8f, 0x52, 0x07, 0x24, 0x92, 0x68, 0x31, 0x36, 0x87, 0x24, 0x92,
+	0xeb, 0x80, 0x29, 0x14, 0x58, 0x8c, 0x8e, 0xa7, 0x90, 0xea, 0x80, 0xa5,
+	0x54, 0xb4, 0x1c, 0x86, 0x97, 0x12, 0xeb, 0x80, 0x29, 0x34, 0xb0, 0x1c,
+	0x67, 0x48, 0x60, 0x8b, 0xf3, 0xff, 0x34, 0xc6, 0x89, 0xb8, 0x1c, 0xc0,
+	0x47, 0x61, 0x82, 0xc3, 0x8a, 0x7d, 0xbb, 0x1f, 0x63, 0x4b, 0x98, 0x7c,
+	0xd8, 0x1c, 0xd0, 0x4c, 0x4d, 0x8b, 0x75, 0x4a, 0xef, 0xfe, 0x3f, 0x7f,
+	0xff, 0xfb, 0x7f, 0x10, 0x00, 0xd1, 0x68, 0xf2, 0xcd, 0xd8, 0x1c, 0x40,
+	0x55, 0xdf, 0x1f, 0xba, 0xfe, 0xf5, 0x3f, 0x32, 0x40, 0x0b, 0x89, 0xd7,
+	0xe8, 0x0e, 0x08, 0xa4, 0xa6, 0xe5, 0x5b, 0xce, 0x7d, 0x46, 0xb1, 0xfc,
+	0xfc, 0x83, 0xef, 0xd8, 0xd4, 0x05, 0x22, 0x3b, 0x80, 0x6a, 0x29, 0x7f,
+	0xd9, 0xd1, 0x8f, 0x28, 0x96, 0xdf, 0x7d, 0xf9, 0xf3, 0xbf, 0x65, 0xdd,
+	0x7d, 0x9c, 0x39, 0x02, 0xe6, 0x80, 0x13, 0xd4, 0xb0, 0x7c, 0xe6, 0x9e,
+	0x77, 0x28, 0x96, 0xbf, 0xfe, 0xf8, 0xfb, 0xbf, 0x7b, 0xfb, 0x23, 0x5c,
+	0xea, 0x4f, 0x20, 0x3b, 0xe0, 0x35, 0x09, 0xa9, 0x18, 0x2b, 0xee, 0xde,
+	0xfc, 0x06, 0xc5, 0xf2, 0x1f, 0xbf, 0xfe, 0xfe, 0x0f, 0xed, 0x7f, 0x42,
+	0xa8, 0x98, 0x86, 0x3b, 0xe0, 0x0f, 0x36, 0x45, 0xc8, 0xa9, 0x58, 0xad,
+	0x10, 0xb7, 0xe5, 0xb5, 0xab, 0x5e, 0xfd, 0x47, 0x07, 0x49, 0x33, 0x9e,
+	0x11, 0x72, 0xf4, 0x1f, 0xbc, 0x0e, 0x00, 0xf9, 0x1c, 0x19, 0x80, 0x12,
+	0x16, 0x36, 0x83, 0x0a, 0x16, 0xbe, 0xc0, 0xb0, 0x1c, 0x24, 0x46, 0x44,
+	0xa8, 0xfd, 0x21, 0x18, 0x05, 0x17, 0x1e, 0x7c, 0x47, 0x31, 0x78, 0xfa,
+	0xee, 0x77, 0x28, 0xf2, 0x20, 0x5f, 0xa2, 0x03, 0x50, 0x68, 0x90, 0x50,
+	0x53, 0xe2, 0x4f, 0x84, 0x4e, 0xcd, 0x0f, 0xff, 0xbf, 0xfd, 0xfc, 0x07,
+	0xc5, 0x82, 0xea, 0x95, 0x10, 0x0b, 0x40, 0xf1, 0x0b, 0x8a, 0x67, 0x64,
+	0x00, 0x4a, 0x07, 0x24, 0x24, 0xda, 0x13, 0x44, 0x65, 0xc3, 0xf8, 0x69,
+	0x4f, 0x31, 0x7c, 0x09, 0x12, 0x03, 0xa5, 0x70, 0x64, 0x00, 0xca, 0x01,
+	0xe4, 0x54, 0x4c, 0x44, 0x15, 0x44, 0x20, 0x5f, 0x23, 0x03, 0xf4, 0x50,
+	0x01, 0xe5, 0x7d, 0x32, 0xb2, 0x6d, 0x20, 0x49, 0x45, 0x31, 0x28, 0xfe,
+	0xb1, 0x01, 0x5c, 0x89, 0x93, 0x98, 0xf8, 0x27, 0xb9, 0x32, 0x42, 0x2f,
+	0x62, 0x41, 0xe5, 0x3d, 0x99, 0x85, 0x56, 0x33, 0x59, 0xd5, 0x31, 0xa8,
+	0x2c, 0x40, 0x2e, 0x1b, 0x40, 0x35, 0x1e, 0x99, 0xbe, 0xe7, 0xa3, 0xa8,
+	0x41, 0x42, 0x6c, 0xe9, 0x48, 0x6a, 0x83, 0x64, 0x50, 0x34, 0xc9, 0x06,
+	0x45, 0xa3, 0x74, 0x50, 0x34, 0xcb, 0x07, 0x45, 0xc7, 0x64, 0x50, 0x74,
+	0xcd, 0x06, 0x45, 0xe7, 0x94, 0xa6, 0x18, 0x00, 0xa7, 0xc9, 0xf8, 0xa5,
+	0xe3, 0x40, 0x25, 0x43, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44,
+	0xae, 0x42, 0x60, 0x82,
+}
+
+// busyIcon is an orange check mark.
+var busyIcon = []byte{
+	0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x20, 0x20, 0x00, 0x00, 0x01, 0x00,
+	0x20, 0x00, 0xf2, 0x01, 0x00, 0x00, 0x16, 0x00, 0x00, 0x00, 0x89, 0x50,
+	0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48,
+	0x44, 0x52, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x20, 0x08, 0x06,
+	0x00, 0x00, 0x00, 0x73, 0x7a, 0x7a, 0xf4, 0x00, 0x00, 0x01, 0xb9, 0x49,
+	0x44, 0x41, 0x54, 0x78, 0xda, 0xcd, 0x57, 0xbd, 0x4a, 0xc5, 0x30, 0x14,
+	0xbe, 0x4f, 0xd0, 0xc9, 0xc5, 0xc9, 0x37, 0x70, 0x75, 0x71, 0x73, 0x74,
+	0xea, 0xe0, 0xe0, 0xd8, 0xc9, 0x07, 0x10, 0x7c, 0x81, 0xfa, 0x02, 0x72,
+	0x8b, 0x08, 0x4e, 0x77, 0x28, 0x08, 0x82, 0x0a, 0xe2, 0x24, 0x88, 0x6e,
+	0x1d, 0xa4, 0x9b, 0x53, 0x37, 0x27, 0x2f, 0x15, 0xd4, 0xfb, 0x67, 0x6b,
+	0x7b, 0x8d, 0x39, 0xd8, 0x4a, 0x4f, 0x6e, 0x7a, 0x9b, 0xa4, 0x29, 0xe6,
+	0xc0, 0x47, 0x21, 0x6d, 0xf3, 0x7d, 0x39, 0x7f, 0x49, 0x7a, 0xef, 0xc7,
+	0x2b, 0xbd, 0xff, 0x84, 0xec, 0x0f, 0x36, 0x85, 0x47, 0x11, 0x50, 0xc4,
+	0x14, 0x79, 0x81, 0xb8, 0x18, 0xf3, 0x8a, 0x6f, 0xb4, 0x0a, 0xb0, 0x28,
+	0xdc, 0x82, 0x84, 0x08, 0x22, 0x2e, 0xfe, 0xb1, 0xda, 0x0a, 0x70, 0x24,
+	0x89, 0x79, 0x42, 0x1c, 0x55, 0x01, 0x5e, 0x0b, 0x62, 0x16, 0x9e, 0xac,
+	0x00, 0x5f, 0x23, 0x79, 0x09, 0x5f, 0x54, 0x80, 0xd7, 0x01, 0x79, 0xad,
+	0x27, 0x78, 0x31, 0x27, 0x1d, 0xc3, 0xa9, 0x13, 0x60, 0xb5, 0x4c, 0x38,
+	0x2e, 0xc6, 0xe7, 0x5b, 0xbc, 0xc4, 0xb4, 0x78, 0x02, 0x5c, 0xad, 0xe4,
+	0xa7, 0x6b, 0x24, 0x7b, 0x79, 0x24, 0x60, 0xf0, 0x64, 0xde, 0xbb, 0x3c,
+	0x01, 0x5a, 0x57, 0xff, 0xf5, 0x7c, 0x47, 0xaa, 0xc6, 0x78, 0x22, 0x66,
+	0x05, 0xd8, 0x3a, 0xc9, 0xd3, 0xe8, 0x0a, 0x91, 0x73, 0x3c, 0x40, 0xca,
+	0x8e, 0xa9, 0x3d, 0xf3, 0xd3, 0xa7, 0x01, 0x22, 0xcf, 0xdf, 0x22, 0xf2,
+	0x31, 0x58, 0xaf, 0xad, 0x88, 0x52, 0x40, 0xa0, 0x83, 0x3c, 0x09, 0xfb,
+	0x88, 0x7c, 0x3e, 0x1d, 0x92, 0xd1, 0xd9, 0x66, 0xdd, 0xf7, 0x41, 0x55,
+	0x40, 0x2c, 0x91, 0xc5, 0x5c, 0x7c, 0x06, 0x87, 0x88, 0xfc, 0x3b, 0x4b,
+	0xc8, 0xf8, 0x62, 0xbb, 0xa9, 0x4d, 0xff, 0x09, 0xc8, 0x79, 0x1f, 0xa1,
+	0x2c, 0x3e, 0x59, 0xad, 0x9d, 0x6c, 0xf6, 0x70, 0x40, 0x58, 0x9b, 0xdc,
+	0xec, 0x36, 0x89, 0xce, 0x97, 0x0a, 0x80, 0x95, 0x57, 0x2d, 0x8d, 0x2e,
+	0xb9, 0x13, 0x4d, 0x6f, 0xf7, 0x16, 0xc8, 0x61, 0x4c, 0xc0, 0x6b, 0x79,
+	0x63, 0x08, 0xb2, 0x61, 0x88, 0x26, 0x4e, 0xc2, 0x23, 0xf4, 0x1e, 0x56,
+	0xc9, 0x1a, 0x78, 0x43, 0x62, 0xa7, 0x5c, 0x9e, 0x84, 0x23, 0x7f, 0x83,
+	0xcc, 0x67, 0xaf, 0x98, 0xe0, 0x7e, 0xff, 0xd7, 0x43, 0x34, 0xbe, 0x10,
+	0xe7, 0xaa, 0x41, 0x1e, 0x48, 0x24, 0x6d, 0x20, 0x54, 0x86, 0x93, 0xeb,
+	0x9d, 0xc5, 0xf8, 0xd2, 0x31, 0xc8, 0x70, 0xec, 0x9d, 0xbe, 0xd2, 0xc6,
+	0x24, 0xd4, 0x88, 0x60, 0xd5, 0xa8, 0xbc, 0x18, 0xaf, 0x40, 0xed, 0x2b,
+	0x94, 0xad, 0x2d, 0xd5, 0x8a, 0x21, 0xfe, 0x3c, 0x83, 0xae, 0xa7, 0x78,
+	0x52, 0x92, 0xdf, 0x8c, 0xa0, 0x12, 0xaa, 0x06, 0xfd, 0x5e, 0xb1, 0x69,
+	0xb9, 0x6a, 0xdb, 0x31, 0xed, 0x05, 0xa8, 0x37, 0xd0, 0x1d, 0x4f, 0x71,
+	0xf5, 0x56, 0xab, 0x03, 0x89, 0x68, 0x77, 0x94, 0x3d, 0x90, 0x18, 0x71,
+	0x24, 0x33, 0xe2, 0x50, 0x6a, 0xc4, 0xb1, 0xdc, 0x88, 0x8b, 0x89, 0x11,
+	0x57, 0x33, 0x23, 0x2e, 0xa7, 0x9d, 0xe2, 0x07, 0xc4, 0x46, 0xa4, 0x39,
+	0xcd, 0x70, 0x98, 0x20, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44,
+	0xae, 0x42, 0x60, 0x82,
+}
+
+// disabledIcon is a grey check mark.
+var disabledIcon = []byte{
+	0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x20, 0x20, 0x00, 0x00, 0x01, 0x00,
+	0x20, 0x00, 0xd8, 0x01, 0x00, 0x00, 0x16, 0x00, 0x00, 0x00, 0x89, 0x50,
+	0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x00, 0x00, 0x0d, 0x49, 0x48,
+	0x44, 0x52, 0x00, 0x00, 0x00, 0x20, 0x00, 0x00, 0x00, 0x20, 0x08, 0x06,
+	0x00, 0x00, 0x00, 0x73, 0x7a, 0x7a, 0xf4, 0x00, 0x00, 0x01, 0x9f, 0x49,
+	0x44, 0x41, 0x54, 0x78, 0xda, 0xcd, 0x57, 0xb1, 0xae, 0x83, 0x20, 0x14,
+	0xed, 0x17, 0xf8, 0x6f, 0xfd, 0x84, 0x7e, 0x8b, 0xab, 0x69, 0x62, 0xe2,
+	0xe0, 0x62, 0x1c, 0x4c, 0x9d, 0x8c, 0x9b, 0x8b, 0xe9, 0xe6, 0xa2, 0x6e,
+	0x6e, 0xdd, 0xba, 0xd5, 0xc1, 0xb8, 0xe9, 0xa4, 0x09, 0xcf, 0xfb, 0xa2,
+	0x2f, 0x40, 0xa1, 0x02, 0xd2, 0x3c, 0x6e, 0x72, 0x62, 0x02, 0xc8, 0x39,
+	0xf7, 0x72, 0xb9, 0xc0, 0xc9, 0x71, 0x9c, 0xd3, 0x7f, 0x42, 0xf6, 0x87,
+	0xf3, 0x02, 0x6f, 0x41, 0xb9, 0xa0, 0x5b, 0x30, 0xaf, 0xe8, 0xd6, 0x36,
+	0x6f, 0x1d, 0xa3, 0x55, 0x80, 0xb5, 0xc0, 0x5e, 0x49, 0x90, 0x20, 0xba,
+	0xf5, 0x1f, 0xeb, 0xa8, 0x80, 0x8b, 0x24, 0x31, 0x4b, 0xc8, 0x45, 0x55,
+	0x80, 0x77, 0x80, 0x98, 0x86, 0x27, 0x2b, 0xe0, 0xa6, 0x91, 0x7c, 0xc3,
+	0x4d, 0x54, 0x80, 0xf7, 0x05, 0x72, 0x6e, 0x24, 0x58, 0x6b, 0x8e, 0xbe,
+	0x8c, 0x0b, 0x4f, 0x80, 0x75, 0x30, 0xe1, 0x98, 0x88, 0xa2, 0x88, 0x95,
+	0x98, 0x16, 0x4b, 0x80, 0xad, 0x93, 0xd8, 0x75, 0x5d, 0xf4, 0x7a, 0xbd,
+	0x10, 0x18, 0x7c, 0xa9, 0x7e, 0x9b, 0x25, 0x40, 0xab, 0xf7, 0xcf, 0xe7,
+	0x13, 0xe1, 0x46, 0x45, 0xa2, 0xa3, 0x05, 0x9c, 0x75, 0x92, 0x3f, 0x1e,
+	0x0f, 0x82, 0x9c, 0x11, 0x01, 0xb4, 0x55, 0x4c, 0xed, 0x99, 0xdf, 0x34,
+	0x0d, 0x41, 0xde, 0xf7, 0x3d, 0xf2, 0x7d, 0x9f, 0xbb, 0x23, 0x36, 0x01,
+	0xa5, 0x0e, 0xf2, 0xba, 0xae, 0x09, 0xf2, 0x61, 0x18, 0x50, 0x18, 0x86,
+	0xbc, 0xf1, 0x25, 0x2e, 0xa0, 0x93, 0xc8, 0x62, 0x26, 0x8a, 0xa2, 0x20,
+	0xc8, 0xa7, 0x69, 0x42, 0x71, 0x1c, 0xef, 0x95, 0xe9, 0x3f, 0x01, 0x33,
+	0x6b, 0x10, 0x9e, 0xc5, 0xd7, 0xeb, 0x95, 0x3b, 0xd9, 0xfd, 0x7e, 0x47,
+	0xb4, 0xa5, 0x69, 0xba, 0x27, 0x7a, 0xfe, 0x28, 0x00, 0x3c, 0xc7, 0x0d,
+	0x12, 0x8b, 0x35, 0x51, 0x96, 0x65, 0x6f, 0xe4, 0xd0, 0x26, 0x10, 0xb5,
+	0x79, 0x77, 0x09, 0xda, 0xb6, 0x25, 0x26, 0xae, 0xaa, 0x8a, 0xe8, 0x07,
+	0x2f, 0x69, 0x83, 0x68, 0x48, 0x9c, 0x94, 0x9f, 0x93, 0x30, 0x08, 0x02,
+	0x34, 0x8e, 0x23, 0x41, 0x90, 0xe7, 0xf9, 0x6f, 0x1f, 0xac, 0x2f, 0xac,
+	0x33, 0x6e, 0x90, 0x07, 0x12, 0x49, 0x5b, 0x0a, 0x6d, 0xc3, 0x24, 0x49,
+	0xde, 0xbc, 0x84, 0x36, 0xc8, 0x70, 0xdc, 0x60, 0x07, 0xa8, 0x1c, 0x4c,
+	0x42, 0x85, 0x08, 0xbc, 0xc6, 0x8d, 0x8e, 0x0a, 0xec, 0x7d, 0x85, 0x6d,
+	0x7b, 0x96, 0x2a, 0xc5, 0xb0, 0xfe, 0x2c, 0xe3, 0x25, 0xa7, 0xc8, 0xfa,
+	0x4b, 0x1f, 0x46, 0x74, 0x89, 0x85, 0x7a, 0xaf, 0x58, 0xb4, 0x6c, 0xa5,
+	0xe3, 0x18, 0x6a, 0x01, 0x5e, 0x1b, 0xe0, 0xc4, 0x53, 0xf4, 0xde, 0x3a,
+	0x74, 0x21, 0x11, 0xad, 0x8e, 0xb2, 0x17, 0x12, 0x23, 0xae, 0x64, 0x46,
+	0x5c, 0x4a, 0x8d, 0xb8, 0x96, 0x1b, 0xf1, 0x30, 0x31, 0xe2, 0x69, 0x66,
+	0xc4, 0xe3, 0xf4, 0xab, 0xf8, 0x01, 0x2f, 0xac, 0xc8, 0xc2, 0xf8, 0xbf,
+	0xe2, 0x59, 0x00, 0x00, 0x00, 0x00, 0x49, 0x45, 0x4e, 0x44, 0xae, 0x42,
+	0x60, 0x82,
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+// copySelection is only implemented on Windows.
+func copySelection() bool {
+	warnf("Auto copy is only supported on Windows")
+	return false
+}
+
+// pasteClipboard is only implemented on Windows.
+func pasteClipboard() {
+	warnf("Auto paste is only supported on Windows")
+}
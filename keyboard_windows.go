@@ -0,0 +1,66 @@
+package main
+
+import "time"
+
+var (
+	keybdEvent                 = user32.NewProc("keybd_event")
+	getClipboardSequenceNumber = user32.NewProc("GetClipboardSequenceNumber")
+)
+
+const (
+	VK_SHIFT   = 0x10
+	VK_CONTROL = 0x11
+	VK_MENU    = 0x12 // Alt
+	VK_LWIN    = 0x5B
+	VK_C       = 0x43
+	VK_V       = 0x56
+
+	KEYEVENTF_KEYUP = 0x0002
+)
+
+// copyTimeout bounds how long copySelection waits for the focused
+// application to put the selection on the clipboard.
+const copyTimeout = 500 * time.Millisecond
+
+// copySelection sends Ctrl+C to the focused window and waits for the
+// clipboard to change. It reports false if nothing new was copied.
+func copySelection() bool {
+	seq, _, _ := getClipboardSequenceNumber.Call()
+	sendShortcut(VK_C)
+
+	deadline := time.Now().Add(copyTimeout)
+	for time.Now().Before(deadline) {
+		if current, _, _ := getClipboardSequenceNumber.Call(); current != seq {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	warnf("Clipboard did not change after sending Ctrl+C")
+	return false
+}
+
+// pasteClipboard sends Ctrl+V to the focused window.
+func pasteClipboard() {
+	sendShortcut(VK_V)
+}
+
+// sendShortcut presses Ctrl+vk. The hotkey's other modifiers and key are
+// released first because the user is usually still holding them, and
+// Ctrl+Alt+C is not a copy.
+func sendShortcut(vk uintptr) {
+	if hotkey.mods&MOD_ALT != 0 {
+		keybdEvent.Call(VK_MENU, 0, KEYEVENTF_KEYUP, 0)
+	}
+	if hotkey.mods&MOD_SHIFT != 0 {
+		keybdEvent.Call(VK_SHIFT, 0, KEYEVENTF_KEYUP, 0)
+	}
+	if hotkey.mods&MOD_WIN != 0 {
+		keybdEvent.Call(VK_LWIN, 0, KEYEVENTF_KEYUP, 0)
+	}
+	keybdEvent.Call(hotkey.vk, 0, KEYEVENTF_KEYUP, 0)
+
+	keybdEvent.Call(VK_CONTROL, 0, 0, 0)
+	keybdEvent.Call(vk, 0, 0, 0)
+	keybdEvent.Call(vk, 0, KEYEVENTF_KEYUP, 0)
+	keybdEvent.Call(VK_CONTROL, 0, KEYEVENTF_KEYUP, 0)
+}
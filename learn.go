@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// accepted holds the words the user confirmed as correct, with how many
+// times each was accepted or chosen from a candidate list. It is persisted
+// to config.AcceptedFile and layered over the dictionary at startup, so
+// corrections adapt without editing the main word list.
+var (
+	acceptedMu sync.Mutex
+	accepted   = map[string]int{}
+)
+
+// loadAccepted adds the words in path to the dictionary, boosting each by
+// its recorded count. A missing file just means nothing was learned yet.
+func loadAccepted(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	acceptedMu.Lock()
+	defer acceptedMu.Unlock()
+	dictionaryMu.Lock()
+	defer dictionaryMu.Unlock()
+	clearMatchCache()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word, count := parseDictionaryLine(strings.TrimSpace(scanner.Text()))
+		if word == "" {
+			continue
+		}
+		word = toLower(word)
+		accepted[word] += max(count, 1)
+		dictionary.bump(word, max(count, 1))
+	}
+	infof("Loaded %d accepted word(s) from %s", len(accepted), path)
+	return scanner.Err()
+}
+
+// acceptWord records that the user confirmed word, either by accepting it
+// as correct or by choosing it from a list of suggestions. The word becomes
+// valid if it wasn't already, and its frequency goes up so that it ranks
+// higher among future candidates.
+func acceptWord(word string) {
+	word = toLower(word)
+
+	acceptedMu.Lock()
+	defer acceptedMu.Unlock()
+	accepted[word]++
+
+	dictionaryMu.Lock()
+	clearMatchCache()
+	dictionary.bump(word, 1)
+	if config.BKTree {
+		bkIndex.insert(word)
+	}
+	dictionaryMu.Unlock()
+
+	if err := saveAccepted(config.AcceptedFile); err != nil {
+		errorf("Failed to save accepted words: %v", err)
+	}
+}
+
+// saveAccepted writes the accepted words to path as sorted "word count"
+// lines. The caller must hold acceptedMu.
+func saveAccepted(path string) error {
+	words := make([]string, 0, len(accepted))
+	for word := range accepted {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	var b strings.Builder
+	for _, word := range words {
+		fmt.Fprintf(&b, "%s %d\n", word, accepted[word])
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// unknownWords returns the distinct words in text that aren't in the
+// dictionary, lowercased and without surrounding punctuation.
+func unknownWords(text string) []string {
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+
+	seen := map[string]bool{}
+	var words []string
+	for _, tok := range tokenize(text) {
+		_, core, _ := splitPunctuation(text[tok.start:tok.end])
+		word := toLower(core)
+		if seen[word] || !strings.ContainsFunc(word, unicode.IsLetter) || isAlphanumericMixed(word) {
+			continue
+		}
+		seen[word] = true
+		if !dictionary.search(word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// rejected maps a misspelling to the corrections the user undid for it.
+// findClosestMatch skips those and falls back to the next best candidate.
+// It is guarded by dictionaryMu, since it is read while text is corrected,
+// and persisted to config.RejectedFile.
+var rejected = map[string]map[string]bool{}
+
+// isRejected reports whether correction was rejected for word. The caller
+// must hold dictionaryMu.
+func isRejected(word, correction string) bool {
+	return rejected[word][correction]
+}
+
+// loadRejected reads "misspelling correction" lines from path. A missing
+// file just means nothing was rejected yet.
+func loadRejected(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dictionaryMu.Lock()
+	defer dictionaryMu.Unlock()
+	clearMatchCache()
+
+	n := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		addRejected(toLower(fields[0]), toLower(fields[1]))
+		n++
+	}
+	infof("Loaded %d rejected correction(s) from %s", n, path)
+	return scanner.Err()
+}
+
+// addRejected records a rejected correction. The caller must hold
+// dictionaryMu for writing.
+func addRejected(word, correction string) {
+	if rejected[word] == nil {
+		rejected[word] = map[string]bool{}
+	}
+	rejected[word][correction] = true
+}
+
+// rejectChanges blacklists the corrections in changes so that the same
+// input is never corrected to the same word again.
+func rejectChanges(changes []Change) {
+	dictionaryMu.Lock()
+	defer dictionaryMu.Unlock()
+	clearMatchCache()
+
+	for _, c := range changes {
+		_, original, _ := splitPunctuation(c.Original)
+		_, corrected, _ := splitPunctuation(c.Corrected)
+		originals := strings.FieldsFunc(toLower(strings.ReplaceAll(original, "’", "'")), isWordSeparator)
+		correcteds := strings.FieldsFunc(toLower(strings.ReplaceAll(corrected, "’", "'")), isWordSeparator)
+		if len(originals) != len(correcteds) {
+			continue
+		}
+		// Compounds like "well-known" or "and/or" are corrected part by part
+		for i := range originals {
+			if originals[i] != correcteds[i] {
+				addRejected(originals[i], correcteds[i])
+				infof("Rejected %s → %s", originals[i], correcteds[i])
+			}
+		}
+	}
+	if err := saveRejected(config.RejectedFile); err != nil {
+		errorf("Failed to save rejected corrections: %v", err)
+	}
+}
+
+// saveRejected writes the rejected corrections to path as sorted
+// "misspelling correction" lines. The caller must hold dictionaryMu.
+func saveRejected(path string) error {
+	var lines []string
+	for word, corrections := range rejected {
+		for correction := range corrections {
+			lines = append(lines, word+" "+correction)
+		}
+	}
+	sort.Strings(lines)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644)
+}
+
+// replacements maps lowercase misspellings to fixed corrections from
+// config.ReplacementsFile, applied before any guessing. It is filled once at
+// startup.
+var replacements = map[string]string{}
+
+// loadReplacements reads "wrong=right" lines from path; blank lines and "#"
+// comments are skipped. A missing file just means there are none.
+func loadReplacements(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n := 0
+	err = readLines(file, func(line string) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return
+		}
+		wrong, right, ok := strings.Cut(line, "=")
+		wrong, right = strings.TrimSpace(wrong), strings.TrimSpace(right)
+		if !ok || wrong == "" || right == "" {
+			warnf("Skipping replacement %q: expected wrong=right", line)
+			return
+		}
+		replacements[toLower(wrong)] = right
+		n++
+	})
+	infof("Loaded %d replacement(s) from %s", n, path)
+	return err
+}
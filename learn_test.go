@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRejectChangesSplitsCompounds(t *testing.T) {
+	useWords(t, "either", "or", "well", "known")
+	in := "eithr/or, wel-known"
+	got, changes := correctText(in)
+	if want := "either/or, well-known"; got != want {
+		t.Fatalf("correctText(%q) = %q, want %q", in, got, want)
+	}
+	rejectChanges(changes)
+	for word, correction := range map[string]string{"eithr": "either", "wel": "well"} {
+		if !isRejected(word, correction) {
+			t.Errorf("%s → %s isn't rejected after undoing %+v", word, correction, changes)
+		}
+	}
+	if got, _ := correctText(in); got != in {
+		t.Errorf("correctText(%q) = %q after rejecting, want it unchanged", in, got)
+	}
+}
+
+func TestAcceptWordAddsLetters(t *testing.T) {
+	useWords(t, "city", "lake")
+	if slices.Contains(dictionary.alphabet, 'ü') {
+		t.Fatalf("alphabet %q already has ü", string(dictionary.alphabet))
+	}
+	acceptWord("Zürich")
+	if !slices.Contains(dictionary.alphabet, 'ü') {
+		t.Errorf("alphabet %q is missing ü after accepting Zürich", string(dictionary.alphabet))
+	}
+	assertCorrects(t, map[string]string{
+		"zürch":       "zürich",
+		"Zurich lake": "Zürich lake",
+	})
+}
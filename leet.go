@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// leetLetters maps the digits used as letters in leetspeak to the letters
+// they may stand for; "1" is both "l" and "i".
+var leetLetters = map[rune]string{
+	'0': "o", '1': "li", '3': "e", '4': "a", '5': "s", '7': "t",
+}
+
+// leetMaxDigits caps the "1"s deleet replaces in one word, since every one
+// doubles the spellings to look up.
+const leetMaxDigits = 6
+
+// leetMinLength is the fewest letters a de-leeted word needs, unless it is
+// seen at least leetMinFrequency times in a dictionary with frequency
+// counts. Shorter spellings are too easy to hit by chance: "a1" is "al".
+const (
+	leetMinLength    = 4
+	leetMinFrequency = 100000
+)
+
+// numberSuffixes are the letters that follow a number as a unit or an
+// ordinal, as in "7am", "5th", "3d" or "4x4".
+var numberSuffixes = map[string]bool{
+	"am": true, "pm": true, "st": true, "nd": true, "rd": true, "th": true, "d": true, "x": true,
+}
+
+// isNumberWithSuffix reports whether the lowercase word starts with a number
+// followed by one of numberSuffixes and nothing or another number after it.
+func isNumberWithSuffix(word string) bool {
+	rest := strings.TrimLeftFunc(word, unicode.IsDigit)
+	if rest == word {
+		return false
+	}
+	letters := rest
+	if i := strings.IndexFunc(rest, unicode.IsDigit); i >= 0 {
+		letters = rest[:i]
+	}
+	return numberSuffixes[letters]
+}
+
+// deleet returns the dictionary word that the lowercase word spells with
+// leetspeak digits replaced by letters, such as "hello" for "h3ll0". It
+// reports false when no spelling is a dictionary word of at least
+// leetMinLength letters or of leetMinFrequency, and when the word has no
+// more leetspeak digits than other digits ("b2b3"), so identifiers like
+// "mp3" are left alone. The caller must hold dictionaryMu.
+func deleet(word string) (string, bool) {
+	leet, other := 0, 0
+	for _, r := range word {
+		if _, ok := leetLetters[r]; ok {
+			leet++
+		} else if unicode.IsDigit(r) {
+			other++
+		}
+	}
+	if leet <= other || strings.Count(word, "1") > leetMaxDigits {
+		return "", false
+	}
+	spellings := []string{""}
+	for _, r := range word {
+		letters, ok := leetLetters[r]
+		if !ok {
+			letters = string(r)
+		}
+		next := make([]string, 0, len(spellings)*len(letters))
+		for _, s := range spellings {
+			for _, l := range letters {
+				next = append(next, s+string(l))
+			}
+		}
+		spellings = next
+	}
+	for _, s := range spellings {
+		if isAlphanumericMixed(s) {
+			continue
+		}
+		if node := dictionary.find(s); node != nil && (len([]rune(s)) >= leetMinLength || dictionary.hasFreq && node.freq >= leetMinFrequency) {
+			return s, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestLeetspeak(t *testing.T) {
+	useWords(t, "hello", "world", "more", "mpe", "tam", "epm", "sth", "ist", "ed", "axa", "al", "ai", "bbe", "loth")
+	config.Leetspeak = true
+	for _, c := range []struct{ in, want string }{
+		{"h3llo", "hello"},
+		{"w0rld", "world"},
+		{"m0r3", "more"},
+		{"H3LL0 w0rld!", "HELLO world!"},
+		{"mp3", "mp3"},
+		{"7am", "7am"},
+		{"3pm", "3pm"},
+		{"5th", "5th"},
+		{"1st", "1st"},
+		{"10th", "10th"},
+		{"3d", "3d"},
+		{"4x4", "4x4"},
+		{"a1", "a1"},
+		{"b2b3", "b2b3"},
+		{"100km", "100km"},
+	} {
+		if got, _ := correctText(c.in); got != c.want {
+			t.Errorf("correctText(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestLeetspeakFrequentShortWords(t *testing.T) {
+	useWords(t)
+	config.Leetspeak = true
+	dictionary = newTrie()
+	dictionary.insertFreq("at", leetMinFrequency)
+	dictionary.insertFreq("al", leetMinFrequency-1)
+	for in, want := range map[string]string{"a7": "at", "a1": "a1"} {
+		if got, _ := correctText(in); got != want {
+			t.Errorf("correctText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// logLevel orders log messages by importance; only messages at or above
+// the current level are written.
+type logLevel int
+
+const (
+	levelError logLevel = iota
+	levelWarn
+	levelInfo
+	levelDebug
+)
+
+var levelNames = []string{"ERROR", "WARN", "INFO", "DEBUG"}
+
+// currentLogLevel defaults to warnings so the tray app stays quiet.
+var currentLogLevel = levelWarn
+
+func parseLogLevel(s string) (logLevel, error) {
+	switch strings.ToLower(s) {
+	case "error":
+		return levelError, nil
+	case "warn", "warning":
+		return levelWarn, nil
+	case "info":
+		return levelInfo, nil
+	case "debug":
+		return levelDebug, nil
+	}
+	return levelWarn, fmt.Errorf("unknown log level %q", s)
+}
+
+// setLogLevel applies a level name, keeping the current level if the name
+// is invalid.
+func setLogLevel(name string) {
+	level, err := parseLogLevel(name)
+	if err != nil {
+		warnf("%v", err)
+		return
+	}
+	currentLogLevel = level
+}
+
+func logf(level logLevel, format string, args ...any) {
+	if level <= currentLogLevel {
+		log.Printf(levelNames[level]+": "+format, args...)
+	}
+}
+
+func errorf(format string, args ...any) { logf(levelError, format, args...) }
+func warnf(format string, args ...any)  { logf(levelWarn, format, args...) }
+func infof(format string, args ...any)  { logf(levelInfo, format, args...) }
+func debugf(format string, args ...any) { logf(levelDebug, format, args...) }
@@ -2,44 +2,147 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"maps"
 	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
-	"syscall"
-	"unsafe"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/getlantern/systray"
-	"github.com/lxn/win"
-)
-
-var (
-	user32           = syscall.NewLazyDLL("user32.dll")
-	getClipboardData = user32.NewProc("GetClipboardData")
-	openClipboard    = user32.NewProc("OpenClipboard")
-	closeClipboard   = user32.NewProc("CloseClipboard")
-	emptyClipboard   = user32.NewProc("EmptyClipboard")
-	setClipboardData = user32.NewProc("SetClipboardData")
 )
 
 const (
-	MOD_ALT  = 0x0001
-	MOD_CTRL = 0x0002
-	VK_S     = 0x53 // Virtual key code for 'S'
+	MOD_ALT   = 0x0001
+	MOD_CTRL  = 0x0002
+	MOD_SHIFT = 0x0004
+	MOD_WIN   = 0x0008
+	VK_S      = 0x53 // Virtual key code for 'S'
+	VK_F1     = 0x70
 )
 
+// hotkey is the shortcut registered by listenHotkey.
+var hotkey = Hotkey{mods: MOD_CTRL | MOD_ALT, vk: VK_S}
+
 // TrieNode represents a node in the Trie
 type TrieNode struct {
 	children map[rune]*TrieNode
 	isEnd    bool
+	freq     int // occurrence count from the dictionary, 0 if unknown
+	rank     int // insertion order; dictionaries list common words first
+	// canonical is the dictionary spelling of words with fixed casing such
+	// as "iPhone" or "GitHub", and empty for ordinary lowercase words.
+	canonical string
 }
 
-// Trie represents the trie data structure
+// Trie represents the trie data structure. Its alphabet is kept up to date
+// by insertFreq as words are added, including accepted words, and reset by
+// Clear, so edits never has to collect the letters itself.
 type Trie struct {
-	root *TrieNode
+	root     *TrieNode
+	words    int
+	hasFreq  bool   // some word was inserted with an occurrence count
+	alphabet []rune // every letter used by the words, sorted
 }
 
 var dictionary *Trie
 
+// dictionaryMu guards dictionary and bkIndex against words being learned
+// while text is corrected.
+var dictionaryMu sync.RWMutex
+
+// contractions maps common contractions typed without their apostrophe to
+// the correct form, with the pronoun "I" capitalized. Words that are also
+// valid on their own ("its", "well", "were", "ill") are deliberately left
+// out, and none is applied to a word the dictionary or the accepted words
+// contain.
+var contractions = map[string]string{
+	"aint":     "ain't",
+	"arent":    "aren't",
+	"cant":     "can't",
+	"couldnt":  "couldn't",
+	"couldve":  "could've",
+	"didnt":    "didn't",
+	"doesnt":   "doesn't",
+	"dont":     "don't",
+	"hadnt":    "hadn't",
+	"hasnt":    "hasn't",
+	"havent":   "haven't",
+	"heres":    "here's",
+	"im":       "I'm",
+	"isnt":     "isn't",
+	"itll":     "it'll",
+	"ive":      "I've",
+	"mightnt":  "mightn't",
+	"mustnt":   "mustn't",
+	"neednt":   "needn't",
+	"shes":     "she's",
+	"shouldnt": "shouldn't",
+	"shouldve": "should've",
+	"thats":    "that's",
+	"theres":   "there's",
+	"theyd":    "they'd",
+	"theyll":   "they'll",
+	"theyre":   "they're",
+	"theyve":   "they've",
+	"wasnt":    "wasn't",
+	"werent":   "weren't",
+	"weve":     "we've",
+	"whats":    "what's",
+	"wheres":   "where's",
+	"whos":     "who's",
+	"wont":     "won't",
+	"wouldnt":  "wouldn't",
+	"wouldve":  "would've",
+	"youd":     "you'd",
+	"youll":    "you'll",
+	"youre":    "you're",
+	"youve":    "you've",
+}
+
+// correctionEnabled is switched from the tray menu. While it is off the
+// hotkey and menu item do nothing.
+var correctionEnabled = true
+
+// bkIndex is built by loadDictionary when config.BKTree is set, so that
+// findClosestMatch can query it instead of generating edits. The Trie is
+// still used for exact lookups.
+var bkIndex *BKTree
+
+// Change records a single word replaced during correction. Offset is the
+// byte position of the original token in the input text, and Confidence is
+// the score from matchConfidence. A removed repeat also takes the spacing
+// before it, so its Offset and Original start there.
+type Change struct {
+	Offset     int     `json:"offset"`
+	Original   string  `json:"original"`
+	Corrected  string  `json:"corrected"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Ambiguity is a word left as it was, or marked, because several candidates
+// tied for its correction under config.Ambiguity.
+type Ambiguity struct {
+	Offset       int      `json:"offset"`
+	Word         string   `json:"word"`
+	Alternatives []string `json:"alternatives"`
+}
+
 func newTrieNode() *TrieNode {
 	return &TrieNode{
 		children: make(map[rune]*TrieNode),
@@ -51,212 +154,2171 @@ func newTrie() *Trie {
 	return &Trie{root: newTrieNode()}
 }
 
+// newTrieFromWords returns a Trie holding words, in their given order, so
+// tests and callers with a word list in memory don't need a dictionary
+// file. Lines such as "word 42" are not parsed; each entry is one word.
+func newTrieFromWords(words []string) *Trie {
+	t := newTrie()
+	for _, word := range words {
+		t.insert(word)
+	}
+	return t
+}
+
 func (t *Trie) insert(word string) {
+	t.insertFreq(word, 0)
+}
+
+// insertFreq inserts word with an occurrence count. Words are keyed by their
+// lowercase form; an entry with capitals is remembered as the canonical
+// casing unless the same word is also listed in lowercase. Inserting a word
+// twice keeps its original rank and the larger count.
+func (t *Trie) insertFreq(word string, freq int) {
+	lower := toLower(word)
 	node := t.root
-	for _, ch := range word {
+	for _, ch := range lower {
 		if _, exists := node.children[ch]; !exists {
 			node.children[ch] = newTrieNode()
+			if i, found := slices.BinarySearch(t.alphabet, ch); !found {
+				t.alphabet = slices.Insert(t.alphabet, i, ch)
+			}
 		}
 		node = node.children[ch]
 	}
-	node.isEnd = true
+	if !node.isEnd {
+		node.isEnd = true
+		node.rank = t.words
+		t.words++
+		if word != lower {
+			node.canonical = word
+		}
+	} else if word == lower {
+		node.canonical = "" // "us" as well as "US": keep the ordinary word
+	}
+	if freq > node.freq {
+		node.freq = freq
+	}
+	if freq > 0 {
+		t.hasFreq = true
+	}
+}
+
+// Clear removes every word, leaving an empty Trie ready to be filled again.
+// The caller must hold dictionaryMu for writing when t is the dictionary.
+func (t *Trie) Clear() {
+	t.root = newTrieNode()
+	t.words = 0
+	t.hasFreq = false
+	t.alphabet = nil
+}
+
+// bump adds n to the frequency count of word, inserting it if needed.
+func (t *Trie) bump(word string, n int) {
+	node := t.find(word)
+	if node == nil {
+		t.insert(word)
+		node = t.find(word)
+	}
+	node.freq += n
 }
 
 func (t *Trie) search(word string) bool {
+	return t.find(word) != nil
+}
+
+// find returns the node that ends word, or nil if word isn't in the Trie.
+func (t *Trie) find(word string) *TrieNode {
 	node := t.root
 	for _, ch := range word {
 		if _, exists := node.children[ch]; !exists {
-			return false
+			return nil
 		}
 		node = node.children[ch]
 	}
-	return node.isEnd
+	if !node.isEnd {
+		return nil
+	}
+	return node
+}
+
+// Words returns every word in the Trie in sorted order, each in its
+// canonical casing.
+func (t *Trie) Words() []string {
+	words := make([]string, 0, t.words)
+	var walk func(node *TrieNode, prefix []rune)
+	walk = func(node *TrieNode, prefix []rune) {
+		if node.isEnd {
+			if node.canonical != "" {
+				words = append(words, node.canonical)
+			} else {
+				words = append(words, string(prefix))
+			}
+		}
+		for ch, child := range node.children {
+			walk(child, append(prefix, ch))
+		}
+	}
+	walk(t.root, nil)
+	sort.Slice(words, func(i, j int) bool {
+		return toLower(words[i]) < toLower(words[j])
+	})
+	return words
+}
+
+// exportWords writes the words of the dictionary, including accepted words,
+// to path one per line, or to stdout if path is "-".
+func exportWords(path string) error {
+	dictionaryMu.RLock()
+	words := dictionary.Words()
+	dictionaryMu.RUnlock()
+
+	data := []byte(strings.Join(words, "\n") + "\n")
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	infof("Exported %d words to %s", len(words), path)
+	return nil
+}
+
+// parseDictionaryLine splits a dictionary line into the word and an optional
+// trailing occurrence count ("the 23135851162").
+func parseDictionaryLine(line string) (string, int) {
+	if i := strings.LastIndexAny(line, " \t"); i > 0 {
+		if freq, err := strconv.Atoi(line[i+1:]); err == nil {
+			return strings.TrimSpace(line[:i]), freq
+		}
+	}
+	return line, 0
 }
 
+// builtinDictionary is the default word list compiled into the executable,
+// used when the configured dictionary file doesn't exist.
+//
+//go:embed dictionary.txt
+var builtinDictionary string
+
 func loadDictionary(filePath string) {
-	dictionary = newTrie()
+	start := time.Now()
+	trie, bk, err := buildDictionary(filePath)
+	if err != nil {
+		log.Fatalf("Failed to load dictionary: %v", err)
+	}
+	dictionary, bkIndex = trie, bk
+	infof("Loaded %d words from %s in %v", trie.words, filePath, time.Since(start).Round(time.Millisecond))
+}
+
+// buildDictionary reads the word list at filePath into a new Trie, and into
+// a BK-tree when config.BKTree is set.
+func buildDictionary(filePath string) (*Trie, *BKTree, error) {
+	trie := newTrie()
+	bk := newBKTree()
+	if err := fillDictionary(trie, bk, filePath); err != nil {
+		return nil, nil, err
+	}
+	return trie, bk, nil
+}
+
+// fillDictionary adds the words of the word list at filePath, the topic
+// dictionaries in config.DictionaryDir and the contractions to trie and bk.
+func fillDictionary(trie *Trie, bk *BKTree, filePath string) error {
+	err := readWordFile(trie, bk, filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		warnf("No dictionary at %s, using the built-in word list", filePath)
+		err = readWordList(trie, bk, "built-in dictionary", strings.NewReader(builtinDictionary))
+	}
+	if err != nil {
+		return err
+	}
+	if config.DictionaryDir != "" {
+		readDictionaryDir(trie, bk, config.DictionaryDir)
+	}
+
+	// Contractions are valid words even if the word list doesn't have them
+	for _, contraction := range contractions {
+		trie.insert(contraction)
+		if config.BKTree {
+			bk.insert(contraction)
+		}
+	}
+	return nil
+}
+
+// readDictionaryDir adds every .txt word list in dir to trie and bk, except
+// those named in config.DisabledDictionaries, so that topic dictionaries
+// such as medical.txt can be switched on and off. A missing directory is
+// not an error, and a file that can't be read is skipped with a warning.
+func readDictionaryDir(trie *Trie, bk *BKTree, dir string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		warnf("Failed to list dictionaries in %s: %v", dir, err)
+		return
+	}
+	for _, path := range paths {
+		if slices.Contains(config.DisabledDictionaries, filepath.Base(path)) {
+			infof("Skipping disabled dictionary %s", path)
+			continue
+		}
+		before := trie.words
+		if err := readWordFile(trie, bk, path); err != nil {
+			warnf("Failed to load dictionary %s: %v", path, err)
+			continue
+		}
+		infof("Loaded %d new words from %s", trie.words-before, path)
+	}
+}
+
+// readWordFile adds the words of a single word list to trie and bk.
+func readWordFile(trie *Trie, bk *BKTree, filePath string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Fatalf("Failed to open dictionary file: %v", err)
+		return err
 	}
 	defer file.Close()
+	return readWordList(trie, bk, filePath, file)
+}
+
+// readWordList adds the words read from file to trie and bk. filePath names
+// the list in messages and tells Hunspell .dic files apart.
+func readWordList(trie *Trie, bk *BKTree, filePath string, file io.Reader) error {
+	r, err := openWordList(file)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", filePath, err)
+	}
+
+	added, invalid := 0, 0
+	add := func(word string, freq int) {
+		if config.StrictDictionary && !isDictionaryWord(word) {
+			invalid++
+			debugf("Skipping dictionary entry %q", word)
+			return
+		}
+		added++
+		trie.insertFreq(word, freq)
+		if config.BKTree {
+			bk.insert(toLower(word))
+		}
+	}
+	if ext := filepath.Ext(filePath); strings.EqualFold(ext, ".dic") {
+		err = readHunspell(r, strings.TrimSuffix(filePath, ext)+".aff", add)
+	} else {
+		first := true
+		err = readLines(r, func(line string) {
+			if first {
+				line = strings.TrimPrefix(line, "\ufeff") // UTF-8 byte order mark
+				first = false
+			}
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				return
+			}
+			add(parseDictionaryLine(line))
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", filePath, err)
+	}
+	if invalid > 0 {
+		warnf("Skipped %d dictionary entries with characters other than letters, apostrophes and hyphens", invalid)
+	}
+	if added == 0 {
+		return fmt.Errorf("%s has no words", filePath)
+	}
+	return nil
+}
+
+// isDictionaryWord reports whether word consists only of letters,
+// apostrophes and hyphens, the entries config.StrictDictionary keeps.
+func isDictionaryWord(word string) bool {
+	for _, r := range word {
+		if !unicode.IsLetter(r) && r != '\'' && r != '’' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// maxLineBytes is the longest dictionary line readLines passes on. A word
+// list should never come close; longer lines come from corrupt or
+// concatenated files.
+const maxLineBytes = 4096
+
+// readLines calls fn with every line of r, without the line ending. Lines over
+// maxLineBytes are skipped with a warning instead of failing the whole load.
+func readLines(r io.Reader, fn func(line string)) error {
+	br := bufio.NewReaderSize(r, maxLineBytes)
+	for n := 1; ; n++ {
+		line, isPrefix, err := br.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !isPrefix {
+			fn(string(line))
+			continue
+		}
+		for isPrefix && err == nil {
+			_, isPrefix, err = br.ReadLine()
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		warnf("Skipping line %d: longer than %d bytes", n, maxLineBytes)
+	}
+}
+
+// openWordList returns a reader for the lines of a word list, transparently
+// decompressing it when it starts with the gzip magic bytes, so a file such
+// as dictionary.txt.gz works whatever its name.
+func openWordList(file io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(file)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// reloadDictionary rebuilds the dictionary from its file and the accepted
+// words, then swaps it in under the write lock so a check in progress never
+// sees a half-built Trie. On error the current dictionary is kept.
+//
+// With config.ReloadInPlace the existing Trie is cleared and refilled
+// instead, saving a second copy in memory at the cost of blocking checks
+// for the whole reload and leaving a partial dictionary if the file can't
+// be read.
+func reloadDictionary() {
+	start := time.Now()
+	acceptedMu.Lock()
+	defer acceptedMu.Unlock()
+
+	var trie *Trie
+	var bk *BKTree
+	var err error
+	if config.ReloadInPlace {
+		dictionaryMu.Lock()
+		defer dictionaryMu.Unlock()
+		clearMatchCache()
+		dictionary.Clear()
+		trie, bk = dictionary, newBKTree()
+		bkIndex = bk
+		err = fillDictionary(trie, bk, config.Dictionary)
+	} else {
+		trie, bk, err = buildDictionary(config.Dictionary)
+	}
+	if err != nil {
+		errorf("Failed to reload dictionary: %v", err)
+		if config.Notify {
+			notify("Spell Checker", "Failed to reload dictionary: "+err.Error())
+		}
+		if !config.ReloadInPlace {
+			return
+		}
+	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		dictionary.insert(strings.ToLower(scanner.Text()))
+	for word, count := range accepted {
+		trie.bump(word, count)
+		if config.BKTree {
+			bk.insert(word)
+		}
 	}
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Failed to read dictionary file: %v", err)
+	if !config.ReloadInPlace {
+		dictionaryMu.Lock()
+		dictionary, bkIndex = trie, bk
+		clearMatchCache()
+		dictionaryMu.Unlock()
+	}
+	if err != nil {
+		return // the in-place dictionary keeps what could be read
 	}
+	infof("Reloaded %d words from %s in %v", trie.words, config.Dictionary, time.Since(start).Round(time.Millisecond))
 }
 
 func main() {
-	loadDictionary("dictionary.txt")
-	// loadDictionary("big_dic.txt")
+	configPath := flag.String("config", "config.json", "path to the JSON config file")
+	inPath := flag.String("in", "", "correct this file (- for stdin) instead of running the tray app")
+	outPath := flag.String("out", "", "write the corrected -in file here instead of stdout")
+	runSelfTest := flag.Bool("selftest", false, "check the dictionary, a few corrections and the hotkey, then exit")
+	exportPath := flag.String("export", "", "write every word the dictionary accepts to this file (- for stdout), then exit")
+	jsonOut := flag.Bool("json", false, "write the -in corrections as JSON instead of the corrected text")
+	interactive := flag.Bool("interactive", false, "review each unknown word of the -in file at a prompt")
+	flag.StringVar(&config.Hotkey, "hotkey", config.Hotkey, "global shortcut that checks the clipboard")
+	flag.StringVar(&config.Dictionary, "dict", config.Dictionary, "dictionary word list")
+	flag.IntVar(&config.MinCorrectLength, "minlength", config.MinCorrectLength, "shortest word that is corrected")
+	flag.IntVar(&config.MaxDistance, "maxdistance", config.MaxDistance, "maximum edit distance for suggestions")
+	flag.IntVar(&config.MaxAutoCorrectDistance, "maxautodistance", config.MaxAutoCorrectDistance, "maximum edit distance applied automatically")
+	flag.BoolVar(&config.Warmup, "warmup", config.Warmup, "look up the corrections of common typos in the background at startup")
+	flag.BoolVar(&config.SentenceCase, "sentencecase", config.SentenceCase, "capitalize the first word of each sentence")
+	flag.BoolVar(&config.DryRun, "dryrun", config.DryRun, "log corrections without modifying the clipboard")
+	flag.IntVar(&config.MaxCandidates, "maxcandidates", config.MaxCandidates, "number of candidates considered per misspelled word")
+	flag.BoolVar(&config.AutoCopy, "autocopy", config.AutoCopy, "send Ctrl+C to copy the selection before checking")
+	flag.BoolVar(&config.AutoPaste, "autopaste", config.AutoPaste, "send Ctrl+V to paste the correction after checking")
+	flag.BoolVar(&config.BKTree, "bktree", config.BKTree, "look up corrections in a BK-tree instead of generating edits")
+	flag.StringVar(&config.Serve, "serve", config.Serve, "serve POST /correct on this address, e.g. :8080")
+	flag.StringVar((*string)(&config.Ranking), "ranking", string(config.Ranking), "candidate ranking strategy: aggressive or conservative")
+	flag.Float64Var(&config.MinConfidence, "minconfidence", config.MinConfidence, "leave words unchanged when the best correction scores below this")
+	flag.IntVar(&config.MinFrequency, "minfrequency", config.MinFrequency, "only suggest words seen more often than this in a frequency dictionary")
+	flag.IntVar(&config.WordTimeout, "wordtimeout", config.WordTimeout, "milliseconds to spend on one word before leaving it unchanged, 0 for no limit")
+	flag.BoolVar(&config.SkipAllCaps, "skipallcaps", config.SkipAllCaps, "leave all-caps words such as acronyms uncorrected")
+	flag.BoolVar(&config.LastWord, "lastword", config.LastWord, "correct only the last word on the clipboard and write back just that word")
+	flag.BoolVar(&config.InPlace, "inplace", config.InPlace, "correct the focused edit control directly instead of the clipboard (Windows)")
+	flag.BoolVar(&config.Annotate, "annotate", config.Annotate, "mark unknown words as [?word?] instead of correcting them")
+	flag.StringVar(&config.Marker, "marker", config.Marker, "only correct words prefixed with this marker, such as *")
+	flag.BoolVar(&config.Suggest, "suggest", config.Suggest, "keep the text as it is and append a list of suggested corrections")
+	flag.BoolVar(&config.RemoveRepeats, "removerepeats", config.RemoveRepeats, "remove accidentally repeated words such as \"the the\"")
+	flag.BoolVar(&config.Watch, "watch", config.Watch, "correct the clipboard whenever it changes")
+	flag.BoolVar(&config.Markdown, "markdown", config.Markdown, "leave Markdown code, fenced blocks and link targets alone")
+	flag.BoolVar(&config.SplitCompounds, "splitcompounds", config.SplitCompounds, "split run-together words like \"alot\" into two")
+	flag.BoolVar(&config.PersistHistory, "persisthistory", config.PersistHistory, "keep the correction history across restarts")
+	flag.StringVar(&config.LogLevel, "loglevel", config.LogLevel, "log verbosity: error, warn, info or debug")
+	flag.Parse()
+	setLogLevel(config.LogLevel)
+
+	// The first Parse only finds the config file; parsing again lets flags
+	// override whatever the file set.
+	if err := loadConfig(*configPath); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if level := os.Getenv("SPELLCHECKER_LOG"); level != "" {
+		config.LogLevel = level
+	}
+	flag.Parse()
+	setLogLevel(config.LogLevel)
+	applyConfig()
+	clipboard = newClipboard()
+
+	if hk, err := parseHotkey(config.Hotkey); err != nil {
+		warnf("Invalid hotkey, using Ctrl+Alt+S: %v", err)
+		config.Hotkey = "Ctrl+Alt+S"
+	} else {
+		hotkey = hk
+	}
+
+	if *runSelfTest {
+		if !selfTest(os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	loadDictionary(config.Dictionary)
+	if err := loadAccepted(config.AcceptedFile); err != nil {
+		warnf("Failed to load accepted words: %v", err)
+	}
+	if err := loadReplacements(config.ReplacementsFile); err != nil {
+		warnf("Failed to load replacements: %v", err)
+	}
+	if err := loadStopWords(config.StopWordsFile); err != nil {
+		warnf("Failed to load stop words: %v", err)
+	}
+	if err := loadRejected(config.RejectedFile); err != nil {
+		warnf("Failed to load rejected corrections: %v", err)
+	}
+	if config.PersistHistory {
+		if err := loadHistory(config.HistoryFile); err != nil {
+			warnf("Failed to load history: %v", err)
+		}
+	}
+	if *exportPath != "" {
+		if err := exportWords(*exportPath); err != nil {
+			log.Fatalf("Failed to export words: %v", err)
+		}
+		return
+	}
+	if *inPath != "" {
+		review := correctFile
+		if *interactive {
+			review = reviewFile
+		} else if *jsonOut {
+			review = correctFileJSON
+		}
+		if err := review(*inPath, *outPath); err != nil {
+			log.Fatalf("Failed to correct %s: %v", *inPath, err)
+		}
+		return
+	}
+	if config.Warmup {
+		go warmup()
+	}
+	if err := checkProcs(); err != nil {
+		log.Fatalf("Missing system functions, this version of Windows is not supported:\n%v", err)
+	}
+	if config.Serve != "" {
+		go serve(config.Serve)
+	}
 	systray.Run(onReady, onExit)
 }
 
+// correctFile corrects the file at inPath and writes the result to outPath,
+// or to stdout if outPath is empty. Line endings are kept as they are since
+// correction only rewrites words.
+func correctFile(inPath, outPath string) error {
+	data, err := readInput(inPath)
+	if err != nil {
+		return err
+	}
+	corrected, changes := correctText(string(data))
+	infof("Corrected %d word(s) in %s", len(changes), inPath)
+	if config.DryRun {
+		corrected = markChanges(string(data), changes)
+	} else if config.Suggest {
+		corrected = withSuggestions(string(data), changes)
+	}
+	return writeOutput(outPath, []byte(corrected))
+}
+
+// cliChange is a Change in the -json output, with the edit distance
+// between the words and the ranked alternatives for the original.
+type cliChange struct {
+	Change
+	Distance   int      `json:"distance"`
+	Candidates []string `json:"candidates"`
+}
+
+// cliResult is the -json output of correcting a file.
+type cliResult struct {
+	Corrected string      `json:"corrected"`
+	Changes   []cliChange `json:"changes"`
+	Ambiguous []Ambiguity `json:"ambiguous,omitempty"`
+}
+
+// correctFileJSON corrects the file at inPath like correctFile, but writes
+// a cliResult describing every change instead of the corrected text, for
+// editor plugins that present the suggestions themselves.
+func correctFileJSON(inPath, outPath string) error {
+	data, err := readInput(inPath)
+	if err != nil {
+		return err
+	}
+	corrected, changes, ambiguous := correctTextReport(string(data))
+	result := cliResult{Corrected: corrected, Changes: []cliChange{}, Ambiguous: ambiguous}
+	for _, c := range changes {
+		_, original, _ := splitPunctuation(strings.TrimLeft(c.Original, " \t\r\n")) // removed repeats start with a space
+		_, replacement, _ := splitPunctuation(c.Corrected)
+		a, b := []rune(toLower(original)), []rune(toLower(replacement))
+		distance, _ := alignmentWithin(a, b, max(len(a), len(b)))
+		_, candidates := Check(original, config.MaxCandidates)
+		if candidates == nil {
+			candidates = []string{}
+		}
+		result.Changes = append(result.Changes, cliChange{Change: c, Distance: distance, Candidates: candidates})
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(outPath, append(out, '\n'))
+}
+
+// readInput reads the file at path, or stdin if path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput writes data to the file at path, or to stdout if path is
+// empty.
+func writeOutput(path string, data []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
 func onReady() {
+	systray.SetIcon(getIcon())
 	systray.SetTitle("Spell Checker")
-	systray.SetTooltip("Copy text, then click here to check spelling")
-	mSpellCheck := systray.AddMenuItem("Check Clipboard Spelling", "Check spelling of clipboard text")
+	systray.SetTooltip("Copy text, then press " + config.Hotkey + " or click here to check spelling")
+	mSpellCheck := systray.AddMenuItem("Check Clipboard Spelling", "Correct the clipboard text and replace it")
+	mPreview := systray.AddMenuItem("Check and Show Result", "Show the corrected clipboard text without replacing it")
+	mEnabled := systray.AddMenuItemCheckbox("Enabled", "Turn spelling correction on or off", correctionEnabled)
+	mNotify := systray.AddMenuItemCheckbox("Notifications", "Show a summary after each correction", config.Notify)
+	mDryRun := systray.AddMenuItemCheckbox("Dry Run", "Log corrections without modifying the clipboard", config.DryRun)
+	mAutoCopy := systray.AddMenuItemCheckbox("Auto Copy Selection", "Send Ctrl+C before checking", config.AutoCopy)
+	mAutoPaste := systray.AddMenuItemCheckbox("Auto Paste Correction", "Send Ctrl+V after checking", config.AutoPaste)
+	mLastWord := systray.AddMenuItemCheckbox("Last Word Only", "Correct only the last word on the clipboard", config.LastWord)
+	mWatch := systray.AddMenuItemCheckbox("Watch Clipboard", "Correct text as soon as it is copied", config.Watch)
+	mLearn := systray.AddMenuItem("Learn Words From Clipboard", "Accept every unknown word in the clipboard as correct")
+	mReload := systray.AddMenuItem("Reload Dictionary", "Read the dictionary file again after editing it")
+	mHistory := systray.AddMenuItem("View History", "Show the corrections made so far")
+	mUndo := systray.AddMenuItem("Undo Last Correction", "Restore the original text and never suggest those corrections again")
+	systray.AddSeparator()
+	mQuit := systray.AddMenuItem("Quit", "Unregister the hotkey and exit")
 	go func() {
 		for {
 			select {
 			case <-mSpellCheck.ClickedCh:
 				checkSpelling()
+			case <-mPreview.ClickedCh:
+				previewSpelling()
+			case <-mEnabled.ClickedCh:
+				toggle(mEnabled, &correctionEnabled)
+				systray.SetIcon(idleIcon())
+			case <-mNotify.ClickedCh:
+				toggle(mNotify, &config.Notify)
+			case <-mDryRun.ClickedCh:
+				toggle(mDryRun, &config.DryRun)
+			case <-mAutoCopy.ClickedCh:
+				toggle(mAutoCopy, &config.AutoCopy)
+			case <-mAutoPaste.ClickedCh:
+				toggle(mAutoPaste, &config.AutoPaste)
+			case <-mLastWord.ClickedCh:
+				toggle(mLastWord, &config.LastWord)
+			case <-mWatch.ClickedCh:
+				toggleWatch(mWatch)
+			case <-mLearn.ClickedCh:
+				learnFromClipboard()
+			case <-mReload.ClickedCh:
+				reloadDictionary()
+			case <-mHistory.ClickedCh:
+				viewHistory()
+			case <-mUndo.ClickedCh:
+				undoLastCorrection()
+			case <-mQuit.ClickedCh:
+				systray.Quit()
+				return
 			}
 		}
 	}()
+	go listenHotkey()
+	watching.Store(config.Watch)
+	go watchClipboard()
 }
 
-func onExit() {
-	// Cleanup
+// toggle flips a boolean setting and its checkbox menu item.
+func toggle(item *systray.MenuItem, setting *bool) {
+	*setting = !*setting
+	if *setting {
+		item.Check()
+	} else {
+		item.Uncheck()
+	}
 }
 
-func checkSpelling() {
-	text := getClipboardText()
-	if text == "" {
-		return
+// toggleWatch flips watching and its checkbox menu item, and wakes the
+// watcher.
+func toggleWatch(item *systray.MenuItem) {
+	on := !watching.Load()
+	watching.Store(on)
+	if on {
+		item.Check()
+	} else {
+		item.Uncheck()
+	}
+	select {
+	case watchToggled <- struct{}{}:
+	default:
 	}
-	correctedText := correctSpelling(text)
-	setClipboardText(correctedText)
 }
 
-func correctSpelling(text string) string {
-	words := strings.Fields(text)
-	var correctedWords []string
+// learnFromClipboard accepts every word in the clipboard that isn't in the
+// dictionary yet.
+func learnFromClipboard() {
+	text, err := clipboard.Read()
+	if err != nil {
+		errorf("Failed to read clipboard: %v", err)
+		clipboardError()
+		return
+	}
+	words := unknownWords(text)
 	for _, word := range words {
-		correctedWord := findClosestMatch(strings.ToLower(word))
-		if correctedWord != "" {
-			correctedWords = append(correctedWords, correctedWord)
-		} else {
-			correctedWords = append(correctedWords, word)
-		}
+		acceptWord(word)
 	}
-	return strings.Join(correctedWords, " ")
+	infof("Learned %d word(s)", len(words))
 }
 
-func findClosestMatch(word string) string {
-	log.Printf("Finding closest match for: %s", word)
-
-	// Remove trailing punctuation
-	word = strings.TrimRight(word, ".!?,:;")
+// lastRun remembers the text before the most recent correction and what was
+// changed in it, for undoLastCorrection.
+var lastRun struct {
+	sync.Mutex
+	text    string
+	changes []Change
+}
 
-	if dictionary.search(word) {
-		log.Printf("Word '%s' found in dictionary", word)
-		return word
+// undoLastCorrection puts the text from before the last correction back on
+// the clipboard and rejects every correction that was made to it.
+func undoLastCorrection() {
+	lastRun.Lock()
+	text, changes := lastRun.text, lastRun.changes
+	lastRun.text, lastRun.changes = "", nil
+	lastRun.Unlock()
+	if len(changes) == 0 {
+		infof("Nothing to undo")
+		return
+	}
+	if err := clipboard.Write(text); err != nil {
+		errorf("Failed to write clipboard: %v", err)
+		clipboardError()
+		return
 	}
+	rejectChanges(changes)
+	infof("Undid %d correction(s)", len(changes))
+}
 
-	candidates := []string{}
+// idleIcon returns the tray icon for when no check is running.
+func idleIcon() []byte {
+	if !correctionEnabled {
+		return getDisabledIcon()
+	}
+	return getIcon()
+}
 
-	// Check for edit distances up to 3
-	for distance := 1; distance <= 3; distance++ {
-		candidates = append(candidates, findCandidates(word, distance)...)
-		if len(candidates) > 0 {
-			break
+func onExit() {
+	stopHotkey()
+	if config.PersistHistory {
+		if err := saveHistory(config.HistoryFile); err != nil {
+			errorf("Failed to save history: %v", err)
 		}
 	}
+}
 
-	log.Printf("Candidates found: %v", candidates)
+// checkDebounce is how long after a check finishes further presses and
+// clipboard changes are ignored. Presses made while a check runs queue up in
+// the hotkey loop, and would otherwise correct the result again or fight
+// over the clipboard.
+const checkDebounce = 300 * time.Millisecond
 
-	if len(candidates) > 0 {
-		return candidates[0] // Return the first candidate
-	}
+// checkBusy is set while runCheck runs a check, and checkDone holds the time
+// the last one finished in Unix nanoseconds.
+var (
+	checkBusy atomic.Bool
+	checkDone atomic.Int64
+)
 
-	log.Printf("No match found for '%s'", word)
-	return word // If no match found, return the original word
+// checkSpelling corrects the focused control or the clipboard for a hotkey
+// press or menu click.
+func checkSpelling() {
+	runCheck("press", func() {
+		if config.InPlace && correctionEnabled && !config.DryRun && correctFocusedControl() {
+			return
+		}
+		correctClipboard(config.AutoCopy)
+	})
 }
 
-func findCandidates(word string, maxDistance int) []string {
-	candidates := []string{}
-	queue := []struct {
-		word     string
-		distance int
-	}{{word, 0}}
+// runCheck runs check unless another check is running or finished less than
+// checkDebounce ago, so hotkey presses and the clipboard watcher never
+// correct the same text twice or fight over the clipboard. trigger names
+// what asked for the check in the log.
+func runCheck(trigger string, check func()) {
+	if !checkBusy.CompareAndSwap(false, true) {
+		debugf("Check already running, ignoring %s", trigger)
+		return
+	}
+	defer checkBusy.Store(false)
+	if time.Since(time.Unix(0, checkDone.Load())) < checkDebounce {
+		debugf("%s within %v of the last check, ignoring it", trigger, checkDebounce)
+		return
+	}
+	defer func() { checkDone.Store(time.Now().UnixNano()) }()
+	check()
+}
 
-	for len(queue) > 0 {
-		current := queue[0]
-		queue = queue[1:]
+// correctClipboard corrects the clipboard text in place, first copying the
+// selection when autoCopy is set. The clipboard watcher never auto-copies,
+// since it reacts to something having been copied already.
+func correctClipboard(autoCopy bool) {
+	if !correctionEnabled {
+		return
+	}
+	systray.SetIcon(getBusyIcon())
+	defer systray.SetIcon(idleIcon())
 
-		if current.distance > maxDistance {
-			continue
+	text, ok := readTextToCheck(autoCopy)
+	if !ok {
+		return
+	}
+	correctedText, changes := correctText(text)
+	if config.DryRun {
+		logChanges(changes)
+		if len(changes) > 0 {
+			log.Printf("Dry run result:\n%s", markChanges(text, changes))
 		}
+		return
+	}
+	infof("Corrected %d word(s)", len(changes))
+	if config.Suggest {
+		correctedText = withSuggestions(text, changes)
+	}
+	correctedText = outputTransforms[config.OutputTransform](correctedText)
+	if err := clipboard.Write(correctedText); err != nil {
+		errorf("Failed to write clipboard: %v", err)
+		clipboardError()
+		return
+	}
+	if len(changes) > 0 {
+		lastRun.Lock()
+		lastRun.text, lastRun.changes = text, changes
+		lastRun.Unlock()
+		recordHistory(changes)
+		rememberCorrected(changes)
+	}
+	if config.AutoPaste {
+		pasteClipboard()
+	}
+	if config.Notify && len(changes) > 0 {
+		notify("Spell Checker", summarizeChanges(changes))
+	}
+}
 
-		if dictionary.search(current.word) {
-			candidates = append(candidates, current.word)
-			continue
+// previewSpelling corrects the clipboard text like checkSpelling but only
+// shows the result side by side with the original, leaving the clipboard
+// as it is.
+func previewSpelling() {
+	if !correctionEnabled {
+		return
+	}
+	systray.SetIcon(getBusyIcon())
+	defer systray.SetIcon(idleIcon())
+
+	text, ok := readTextToCheck(config.AutoCopy)
+	if !ok {
+		return
+	}
+	correctedText, changes := correctText(text)
+
+	var b strings.Builder
+	if len(changes) == 0 {
+		b.WriteString("No corrections.\n\n")
+	} else {
+		fmt.Fprintf(&b, "%d correction(s):\n", len(changes))
+		for _, c := range changes {
+			fmt.Fprintf(&b, "  %s → %s\n", c.Original, c.Corrected)
 		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "Before:\n%s\n\nAfter:\n%s\n", text, correctedText)
+	path := filepath.Join(os.TempDir(), "spell-checker-preview.txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		errorf("Failed to write preview: %v", err)
+		return
+	}
+	openFile(path)
+}
 
-		if current.distance == maxDistance {
-			continue
+// readTextToCheck copies the selection if autoCopy is set and reads the
+// clipboard. With config.LastWord only the last word of the clipboard is
+// returned, so that just that word is corrected and written back. It
+// reports false when there is nothing to check or the text is over
+// config.MaxTextLength.
+func readTextToCheck(autoCopy bool) (string, bool) {
+	if autoCopy && !copySelection() {
+		return "", false
+	}
+	text, err := clipboard.Read()
+	if err != nil {
+		errorf("Failed to read clipboard: %v", err)
+		clipboardError()
+		return "", false
+	}
+	if config.LastWord {
+		tokens := tokenize(text)
+		if len(tokens) == 0 {
+			return "", false
+		}
+		last := tokens[len(tokens)-1]
+		text = text[last.start:last.end]
+	}
+	if text == "" {
+		return "", false
+	}
+	if n := utf8.RuneCountInString(text); config.MaxTextLength > 0 && n > config.MaxTextLength {
+		warnf("Clipboard text is %d characters, over the %d character limit; not checking it", n, config.MaxTextLength)
+		if config.Notify {
+			notify("Spell Checker", "Clipboard text is too long to check ("+strconv.Itoa(n)+" characters)")
 		}
+		return "", false
+	}
+	return text, true
+}
 
-		// Generate all possible edits
-		for i := 0; i <= len(current.word); i++ {
-			// Deletions
-			if i < len(current.word) {
-				newWord := current.word[:i] + current.word[i+1:]
-				queue = append(queue, struct {
-					word     string
-					distance int
-				}{newWord, current.distance + 1})
-			}
-
-			// Insertions
-			for ch := 'a'; ch <= 'z'; ch++ {
-				newWord := current.word[:i] + string(ch) + current.word[i:]
-				queue = append(queue, struct {
-					word     string
-					distance int
-				}{newWord, current.distance + 1})
-			}
-
-			// Substitutions
-			if i < len(current.word) {
-				for ch := 'a'; ch <= 'z'; ch++ {
-					newWord := current.word[:i] + string(ch) + current.word[i+1:]
-					queue = append(queue, struct {
-						word     string
-						distance int
-					}{newWord, current.distance + 1})
-				}
-			}
+// maxNotifiedChanges is how many corrections a notification lists by name.
+const maxNotifiedChanges = 5
 
-			// Transpositions
-			if i < len(current.word)-1 {
-				newWord := current.word[:i] + string(current.word[i+1]) + string(current.word[i]) + current.word[i+2:]
-				queue = append(queue, struct {
-					word     string
-					distance int
-				}{newWord, current.distance + 1})
-			}
+// summarizeChanges describes corrections for a notification, e.g.
+// "Corrected 2 words: teh→the, wrld→world".
+func summarizeChanges(changes []Change) string {
+	noun := "words"
+	if len(changes) == 1 {
+		noun = "word"
+	}
+	var pairs []string
+	for i, c := range changes {
+		if i == maxNotifiedChanges {
+			pairs = append(pairs, "…")
+			break
 		}
+		pairs = append(pairs, c.Original+"→"+c.Corrected)
 	}
+	return fmt.Sprintf("Corrected %d %s: %s", len(changes), noun, strings.Join(pairs, ", "))
+}
 
-	return candidates
+// logChanges writes a word-by-word diff of a dry run to the log. It
+// bypasses the log level since the user explicitly asked for it.
+func logChanges(changes []Change) {
+	if len(changes) == 0 {
+		log.Printf("Dry run: no changes")
+		return
+	}
+	log.Printf("Dry run: %d change(s)", len(changes))
+	for _, c := range changes {
+		log.Printf("  @%d %s -> %s", c.Offset, c.Original, c.Corrected)
+	}
+}
+
+func correctSpelling(text string) string {
+	corrected, _ := correctText(text)
+	return corrected
 }
 
-func getClipboardText() string {
-	openClipboard.Call(0)
-	defer closeClipboard.Call()
-	h, _, _ := getClipboardData.Call(win.CF_UNICODETEXT)
-	if h == 0 {
-		return ""
-	}
-	p := win.GlobalLock(win.HGLOBAL(h))
-	defer win.GlobalUnlock(win.HGLOBAL(h))
-	return syscall.UTF16ToString((*[1 << 20]uint16)(unsafe.Pointer(p))[:])
-}
-
-func setClipboardText(text string) {
-	openClipboard.Call(0)
-	defer closeClipboard.Call()
-	emptyClipboard.Call()
-	utf16, _ := syscall.UTF16FromString(text)
-	h := win.GlobalAlloc(win.GMEM_MOVEABLE, uintptr(len(utf16)*2))
-	p := win.GlobalLock(h)
-	copy((*[1 << 20]uint16)(unsafe.Pointer(p))[:], utf16)
-	win.GlobalUnlock(h)
-	setClipboardData.Call(win.CF_UNICODETEXT, uintptr(h))
+// correctText corrects text and also returns every word it replaced, in
+// order of appearance. Everything between words is copied through
+// unchanged, so whitespace and line breaks survive byte for byte, and text
+// without corrections comes back exactly as it was.
+func correctText(text string) (string, []Change) {
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+	corrected, changes, _ := correctTextLocked(text)
+	return corrected, changes
+}
+
+// correctTextReport is correctText that also returns the words it didn't
+// correct because several candidates tied, for the callers that report them.
+func correctTextReport(text string) (string, []Change, []Ambiguity) {
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+	return correctTextLocked(text)
+}
+
+// CorrectBatch corrects each of texts like correctSpelling, returning the
+// results in the same order. The read lock is taken once for the whole
+// batch, so none of it is corrected against a dictionary reloaded halfway,
+// and corrections found for one text are cached for the rest. It is safe
+// for concurrent use.
+func CorrectBatch(texts []string) []string {
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+	results := make([]string, len(texts))
+	for i, text := range texts {
+		results[i], _, _ = correctTextLocked(text)
+	}
+	return results
+}
+
+// correctTextLocked is correctTextReport for callers that hold
+// dictionaryMu.
+func correctTextLocked(text string) (string, []Change, []Ambiguity) {
+	start := time.Now()
+	if dictionary.words == 0 {
+		debugf("Dictionary is empty, leaving text unchanged")
+		return text, nil, nil
+	}
+
+	apostrophe := apostropheStyle(text)
+	tokens := checkedTokens(text)
+	words := tokens
+	if config.Marker != "" {
+		tokens, words = markedTokens(text, tokens)
+	}
+	corrections := correctWords(text, words, apostrophe)
+	corrected, changes, ambiguous, changed := applyCorrections(text, tokens, corrections, nil)
+	if n := config.MaxCorrectionsPerRun; n > 0 {
+		// A word left as typed changes what the next one is compared with
+		// for repeats, so the changes are counted again until they fit
+		kept := map[int]bool{}
+		for len(changes) > n {
+			maps.Copy(kept, limitChanges(changes, changed, n))
+			corrected, changes, ambiguous, changed = applyCorrections(text, tokens, corrections, kept)
+		}
+	}
+
+	if len(changes) == 0 && corrected != text {
+		errorf("Rebuilding the text without corrections changed it, leaving it as it was")
+		corrected = text
+	}
+	if strings.Contains(text, "<!--") {
+		corrected = stripNospell(corrected)
+	}
+	if config.SentenceCase {
+		corrected = capitalizeSentences(corrected)
+	}
+	observeCorrection(len(tokens), len(changes), time.Since(start))
+	return corrected, changes, ambiguous
+}
+
+// applyCorrections writes text with the corrections of tokens, as well as
+// ambiguity marks and repeat removal, and returns it with the changes made,
+// the ambiguous words and the index in tokens of each change. Tokens in
+// kept are left exactly as typed. The caller must hold dictionaryMu.
+func applyCorrections(text string, tokens []token, corrections []correction, kept map[int]bool) (string, []Change, []Ambiguity, []int) {
+	var b strings.Builder
+	var changes []Change
+	var changed []int
+	var ambiguous []Ambiguity
+	last := 0
+	previous := ""
+	for i, tok := range tokens {
+		if tok.start < last {
+			// Overlapping tokens would copy text twice; it was already
+			// written through as part of the previous one
+			warnf("Skipping token at %d overlapping text up to %d", tok.start, last)
+			continue
+		}
+		word := text[tok.start:tok.end]
+		if kept[i] {
+			b.WriteString(text[last:tok.end])
+			previous, last = word, tok.end
+			continue
+		}
+		correctedWord := corrections[i].word
+		if correctedWord != word && correctedRecently(word) {
+			correctedWord = word // put back by the user since the last check
+		}
+		if correctedWord == word && config.Ambiguity != "pick" {
+			if alternatives := tiedAlternatives(word); alternatives != nil {
+				infof("Ambiguous correction for '%s': %s", word, strings.Join(alternatives, ", "))
+				ambiguous = append(ambiguous, Ambiguity{Offset: tok.start, Word: word, Alternatives: alternatives})
+				if config.Ambiguity == "mark" {
+					prefix, core, suffix := splitPunctuation(word)
+					correctedWord = prefix + "[?" + core + ": " + strings.Join(alternatives, "|") + "?]" + suffix
+				}
+			}
+		}
+		if config.RemoveRepeats && isRepeat(previous, correctedWord) {
+			// Drop the repeat and the space before it, keeping only its
+			// trailing punctuation: "the the." becomes "the."
+			_, _, suffix := splitPunctuation(correctedWord)
+			changes = append(changes, Change{Offset: last, Original: text[last:tok.end], Corrected: suffix, Confidence: 1})
+			changed = append(changed, i)
+			b.WriteString(suffix)
+			last = tok.end
+			continue
+		}
+		previous = correctedWord
+		b.WriteString(text[last:tok.start])
+		end := tok.end
+		if correctedWord != word && config.Alignment != "" {
+			correctedWord, end = aligned(text, tok, correctedWord)
+		}
+		if correctedWord != word {
+			changes = append(changes, Change{Offset: tok.start, Original: word, Corrected: correctedWord, Confidence: corrections[i].confidence})
+			changed = append(changed, i)
+		}
+		b.WriteString(correctedWord)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String(), changes, ambiguous, changed
+}
+
+// limitChanges picks the n most confident of changes, earlier ones first
+// among equals, and returns the indexes in changed of the tokens to leave
+// as typed instead, so in marker mode those keep their marker for a later
+// check. Corrections, ambiguity marks and removed repeats all count.
+func limitChanges(changes []Change, changed []int, n int) map[int]bool {
+	order := make([]int, len(changes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return changes[order[a]].Confidence > changes[order[b]].Confidence
+	})
+	kept := map[int]bool{}
+	for _, i := range order[n:] {
+		kept[changed[i]] = true
+	}
+	infof("Skipped %d change(s) over the limit of %d per run", len(changes)-n, n)
+	return kept
+}
+
+// tiedAlternatives returns the candidates that tied for the correction of
+// word, if findClosestMatch left it unchanged for that reason. Compounds and
+// possessives are corrected part by part, so their parts are looked up the
+// same way: for "cst's-price" with "cst" tied between "cut" and "cost", the
+// alternatives are "cut's-price" and "cost's-price". The caller must hold
+// dictionaryMu.
+func tiedAlternatives(word string) []string {
+	_, core, _ := splitPunctuation(word)
+	if dictionary.search(toLower(strings.ReplaceAll(core, "’", "'"))) {
+		return nil
+	}
+	for start := 0; start < len(core); {
+		end := strings.IndexFunc(core[start:], isWordSeparator)
+		if end < 0 {
+			end = len(core)
+		} else {
+			end += start
+		}
+		root, _ := splitPossessive(core[start:end])
+		if m, _ := cachedCorrection(toLower(strings.ReplaceAll(root, "’", "'"))); m.alternatives != nil {
+			if root == core {
+				return m.alternatives
+			}
+			alternatives := make([]string, len(m.alternatives))
+			for i, a := range m.alternatives {
+				alternatives[i] = core[:start] + a + core[start+len(root):]
+			}
+			return alternatives
+		}
+		_, size := utf8.DecodeRuneInString(core[end:])
+		start = end + size
+	}
+	return nil
+}
+
+// tiedCandidates returns the words of candidates, ranked best first, that
+// are as good as the best one: at the same distance, with a frequency at
+// most config.TieThreshold (a fraction) below it. It returns at most
+// config.MaxCandidates words.
+func tiedCandidates(candidates []Candidate) []string {
+	var tied []string
+	best := candidates[0]
+	for _, c := range candidates {
+		if len(tied) == config.MaxCandidates {
+			break
+		}
+		if c.Distance == best.Distance && float64(c.freq) >= float64(best.freq)*(1-config.TieThreshold) {
+			tied = append(tied, c.Word)
+		}
+	}
+	return tied
+}
+
+// aligned fits the correction of tok to the width of the original word so
+// that the columns after it stay in place, as config.Alignment says. A
+// shorter correction is padded with spaces. A longer one at the end of a
+// line is kept, and elsewhere takes the spare spaces after the word, keeping
+// at least one; what is still too long is left uncorrected. "skip" leaves
+// every correction that changes the width uncorrected. It also returns the
+// offset up to which text was used.
+func aligned(text string, tok token, corrected string) (string, int) {
+	word := text[tok.start:tok.end]
+	width, want := utf8.RuneCountInString(corrected), utf8.RuneCountInString(word)
+	switch {
+	case width == want:
+		return corrected, tok.end
+	case config.Alignment == "skip":
+		return word, tok.end
+	case width < want:
+		return corrected + strings.Repeat(" ", want-width), tok.end
+	}
+
+	room := len(text[tok.end:]) - len(strings.TrimLeft(text[tok.end:], " "))
+	if next := tok.end + room; next == len(text) || text[next] == '\n' || text[next] == '\r' {
+		return corrected, tok.end // nothing after it to keep aligned
+	}
+	room = max(room-1, 0) // keep a space before the next column
+	extra := width - want
+	if extra <= room {
+		return corrected, tok.end + extra
+	}
+	return word, tok.end
+}
+
+// isRepeat reports whether word repeats the word before it, ignoring case,
+// as in "the the". Punctuation between the two ("the. The") means they aren't
+// a repeat, and words in config.AllowedRepeats such as "had" may repeat.
+func isRepeat(previous, word string) bool {
+	_, prevCore, prevSuffix := splitPunctuation(previous)
+	prefix, core, _ := splitPunctuation(word)
+	if prevSuffix != "" || prefix != "" || !strings.ContainsFunc(core, unicode.IsLetter) || !strings.EqualFold(prevCore, core) {
+		return false
+	}
+	return !allowedRepeats[toLower(core)]
+}
+
+// parallelMinTokens is the token count from which correctWords spreads the
+// work over several goroutines; below it the overhead isn't worth it.
+const parallelMinTokens = 256
+
+// correction is the result of correcting one token.
+type correction struct {
+	word       string
+	confidence float64
+}
+
+// correctWords corrects every token of text, returning the results in token
+// order. Long texts are split over a worker pool per CPU. The caller must
+// hold dictionaryMu, which keeps the dictionary read-only for all workers.
+func correctWords(text string, tokens []token, apostrophe rune) []correction {
+	results := make([]correction, len(tokens))
+	workers := min(runtime.NumCPU(), len(tokens)/parallelMinTokens+1)
+	if workers <= 1 {
+		for i, tok := range tokens {
+			results[i].word, results[i].confidence = correctWord(text[tok.start:tok.end], apostrophe)
+		}
+		return results
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].word, results[i].confidence = correctWord(text[tokens[i].start:tokens[i].end], apostrophe)
+			}
+		}()
+	}
+	for i := range tokens {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// token is the byte span of a run of non-space characters in a text.
+type token struct {
+	start, end int
+}
+
+// tokenize splits text into whitespace-delimited tokens, recording where
+// each one starts and ends.
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				tokens = append(tokens, token{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start, len(text)})
+	}
+	return tokens
+}
+
+// checkedTokens returns the tokens of text that should be corrected: all of
+// them except placeholders, nospell regions, lines starting with one of
+// config.SkipLinePrefixes and, in Markdown mode, code.
+func checkedTokens(text string) []token {
+	tokens := excludeSpans(tokenize(text), placeholderSpans(text))
+	tokens = excludeSpans(tokens, nospellSpans(text))
+	if len(config.SkipLinePrefixes) > 0 {
+		tokens = excludeSpans(tokens, skippedLines(text))
+	}
+	if config.Markdown {
+		tokens = excludeSpans(tokens, markdownCode(text))
+	}
+	return tokens
+}
+
+// skippedLines returns the byte spans of the lines of text that start with
+// one of config.SkipLinePrefixes after any indentation, such as "//" or "#"
+// for code comments.
+func skippedLines(text string) []token {
+	var spans []token
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd := strings.IndexByte(text[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += lineStart + 1
+		}
+		line := strings.TrimLeft(text[lineStart:lineEnd], " \t")
+		for _, prefix := range config.SkipLinePrefixes {
+			if prefix != "" && strings.HasPrefix(line, prefix) {
+				spans = append(spans, token{lineStart, lineEnd})
+				break
+			}
+		}
+		lineStart = lineEnd
+	}
+	return spans
+}
+
+// markedTokens picks the tokens of text that start with config.Marker, the
+// only ones corrected in marker mode. It returns them whole, so the marker is
+// replaced along with the word, and without the marker, for correcting.
+func markedTokens(text string, tokens []token) (marked, words []token) {
+	for _, tok := range tokens {
+		if strings.HasPrefix(text[tok.start:tok.end], config.Marker) && tok.end-tok.start > len(config.Marker) {
+			marked = append(marked, tok)
+			words = append(words, token{tok.start + len(config.Marker), tok.end})
+		}
+	}
+	return marked, words
+}
+
+// nospellStart and nospellEnd mark a region of text that is left exactly as
+// it is. The markers themselves are removed from the corrected text.
+const (
+	nospellStart = "<!--nospell-->"
+	nospellEnd   = "<!--/nospell-->"
+)
+
+// nospellSpans returns the byte spans of text from each nospellStart to the
+// nospellEnd after it, markers included. A region left open runs to the end
+// of text, and a stray nospellEnd covers just itself.
+func nospellSpans(text string) []token {
+	var spans []token
+	for i := 0; i < len(text); {
+		start := strings.Index(text[i:], "<!--")
+		if start < 0 {
+			break
+		}
+		start += i
+		switch {
+		case strings.HasPrefix(text[start:], nospellStart):
+			end := strings.Index(text[start:], nospellEnd)
+			if end < 0 {
+				return append(spans, token{start, len(text)})
+			}
+			i = start + end + len(nospellEnd)
+			spans = append(spans, token{start, i})
+		case strings.HasPrefix(text[start:], nospellEnd):
+			i = start + len(nospellEnd)
+			spans = append(spans, token{start, i})
+		default:
+			i = start + len("<!--")
+		}
+	}
+	return spans
+}
+
+// stripNospell removes the nospell markers from text.
+func stripNospell(text string) string {
+	text = strings.ReplaceAll(text, nospellStart, "")
+	return strings.ReplaceAll(text, nospellEnd, "")
+}
+
+// placeholderSpans returns the byte spans of text matched by any of the
+// placeholder patterns, such as "{{name}}" in templated text, in text order
+// with overlapping matches merged.
+func placeholderSpans(text string) []token {
+	var spans []token
+	for _, re := range placeholders {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			spans = append(spans, token{m[0], m[1]})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	merged := spans[:0]
+	for _, span := range spans {
+		if n := len(merged); n > 0 && span.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, span.end)
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// apostropheStyle reports whether text mostly uses straight (') or curly (’)
+// apostrophes, so that inserted contractions blend in.
+func apostropheStyle(text string) rune {
+	if strings.Count(text, "’") > strings.Count(text, "'") {
+		return '’'
+	}
+	return '\''
+}
+
+// correctWord corrects a single whitespace-delimited token, keeping any
+// surrounding punctuation and the original capitalization. Apostrophes in
+// the result are written in the given style.
+func correctWord(word string, apostrophe rune) (string, float64) {
+	if config.Homoglyphs {
+		if fixed, ok := unconfuse(word); ok {
+			infof("Replaced look-alike letters from another script in '%s' with Latin ones", word)
+			return correctWord(fixed, apostrophe)
+		}
+	}
+	prefix, core, suffix := splitPunctuation(word)
+	if matchesSkipPattern(word) || matchesSkipPattern(core) {
+		return word, 1
+	}
+	if !strings.ContainsFunc(core, unicode.IsLetter) {
+		return word, 1 // bullets, dashes, numbers and other non-words
+	}
+	if strings.ContainsFunc(core, isInvisible) {
+		return word, 1 // edits would drop zero-width spaces and soft hyphens
+	}
+	if isAlphanumericMixed(core) {
+		if lower := toLower(core); config.Leetspeak && !isNumberWithSuffix(lower) && !dictionary.search(lower) {
+			if fixed, ok := deleet(lower); ok {
+				return prefix + matchCase(core, fixed) + suffix, 1
+			}
+		}
+		return word, 1 // identifiers like "mp3" or "B2B", units like "10kg"
+	}
+	if config.SkipAllCaps && isAllCaps(core) {
+		return word, 1 // acronyms like "ASAP" or "JSON"
+	}
+
+	lower := toLower(strings.ReplaceAll(core, "’", "'"))
+	if ignoreWords[lower] || stopWords[lower] {
+		return word, 1
+	}
+	if root, possessive := splitPossessive(core); possessive != "" && !dictionary.search(lower) {
+		corrected, confidence := correctWord(root, apostrophe)
+		return prefix + corrected + possessive + suffix, confidence
+	}
+	if strings.ContainsFunc(core, isWordSeparator) && !dictionary.search(lower) {
+		corrected, confidence := correctCompound(core, apostrophe)
+		return prefix + corrected + suffix, confidence
+	}
+	if r, _ := utf8.DecodeRuneInString(suffix); suffix != "" && !isWordSeparator(r) && dictionary.search(lower+string(r)) {
+		return word, 1 // "Mr." or "etc." listed with their period
+	}
+	if strings.ContainsFunc(strings.ReplaceAll(lower, "'", ""), unicode.IsPunct) && !dictionary.search(lower) {
+		// Abbreviations like "e.g." when "." is word-internal: edits only
+		// produce letters, so guessing could only mangle them
+		return word, 1
+	}
+	if config.Annotate {
+		// Proofreading: mark unknown words instead of guessing
+		if dictionary.search(lower) {
+			return word, 1
+		}
+		return prefix + "[?" + core + "?]" + suffix, 1
+	}
+	confidence := 1.0
+	correctedWord, ok := replacements[lower]
+	if !ok && !dictionary.search(lower) {
+		// A dictionary or accepted word such as German "im" stays as typed
+		correctedWord, ok = contractions[lower]
+	}
+	if ok && isRejected(lower, toLower(correctedWord)) {
+		ok = false
+	}
+	if !ok {
+		if len([]rune(lower)) < config.MinCorrectLength {
+			return word, 1 // too short to guess reliably
+		}
+		correctedWord, confidence = correctWith(corrector, lower)
+	}
+	if correctedWord == "" {
+		return word, 1
+	}
+	if apostrophe != '\'' {
+		correctedWord = strings.ReplaceAll(correctedWord, "'", string(apostrophe))
+	}
+	if node := dictionary.find(correctedWord); node != nil && node.canonical != "" && !isAllCaps(core) {
+		return prefix + node.canonical + suffix, confidence
+	}
+	return prefix + matchCase(core, correctedWord) + suffix, confidence
+}
+
+// matchesSkipPattern reports whether s matches any configured skip pattern.
+func matchesSkipPattern(s string) bool {
+	for _, re := range skipPatterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPunctuation separates leading and trailing punctuation from a token.
+func splitPunctuation(word string) (prefix, core, suffix string) {
+	core = strings.TrimLeft(word, "\"'‘’“([{")
+	prefix = word[:len(word)-len(core)]
+	core = strings.TrimRight(core, ".!?,:;\"'’”)]}")
+	suffix = word[len(prefix)+len(core):]
+	return prefix, core, suffix
+}
+
+// splitPossessive splits a possessive "'s" off word ("John's" → "John",
+// "'s"). Plural possessives such as "dogs'" need no help, since
+// splitPunctuation already treats the trailing apostrophe as punctuation.
+func splitPossessive(word string) (root, possessive string) {
+	for _, apostrophe := range []string{"'", "’"} {
+		for _, s := range []string{"s", "S"} {
+			if root, ok := strings.CutSuffix(word, apostrophe+s); ok && root != "" {
+				return root, apostrophe + s
+			}
+		}
+	}
+	return word, ""
+}
+
+// correctCompound corrects each part of a compound such as "well-knwn" or
+// "and/orr" on its own, keeping the separators where they were. The
+// confidence is that of the least certain part.
+func correctCompound(compound string, apostrophe rune) (string, float64) {
+	var b strings.Builder
+	confidence := 1.0
+	for len(compound) > 0 {
+		i := strings.IndexFunc(compound, isWordSeparator)
+		if i < 0 {
+			i = len(compound)
+		}
+		if i > 0 {
+			part, c := correctWord(compound[:i], apostrophe)
+			b.WriteString(part)
+			confidence = min(confidence, c)
+		}
+		if i < len(compound) {
+			_, size := utf8.DecodeRuneInString(compound[i:])
+			b.WriteString(compound[i : i+size])
+			i += size
+		}
+		compound = compound[i:]
+	}
+	return b.String(), confidence
+}
+
+// isWordSeparator reports whether r is punctuation that separates two
+// words inside a token, like the hyphen in "well-known", rather than
+// belonging to the word the way config.WordPunctuation characters do.
+func isWordSeparator(r rune) bool {
+	if r == '’' {
+		r = '\''
+	}
+	return unicode.IsPunct(r) && !strings.ContainsRune(config.WordPunctuation, r)
+}
+
+// isInvisible reports whether r is a format character such as a zero-width
+// space or a soft hyphen, which takes no room on screen.
+func isInvisible(r rune) bool {
+	return unicode.Is(unicode.Cf, r)
+}
+
+// isAlphanumericMixed reports whether word mixes letters with at least one
+// digit. Such tokens are usually codes, identifiers or numbers with units
+// and ordinals ("10kg", "3pm", "2nd"), not misspellings.
+func isAlphanumericMixed(word string) bool {
+	return strings.ContainsFunc(word, unicode.IsDigit) && strings.ContainsFunc(word, unicode.IsLetter)
+}
+
+// matchCase applies the capitalization of original to corrected. All-caps
+// words stay all-caps and capitalized words stay capitalized.
+func matchCase(original, corrected string) string {
+	if isAllCaps(original) {
+		return toUpper(corrected)
+	}
+	first := []rune(original)[0]
+	if unicode.IsUpper(first) {
+		runes := []rune(corrected)
+		runes[0] = toUpperRune(runes[0])
+		return string(runes)
+	}
+	return corrected
+}
+
+// isAllCaps reports whether word is at least two runes long and has letters
+// that are all upper case, like "NASA".
+func isAllCaps(word string) bool {
+	return len([]rune(word)) > 1 && word == toUpper(word) && word != toLower(word)
+}
+
+// capitalizeSentences uppercases the first letter of the text and of every
+// word following sentence-ending punctuation. A line break is whitespace like
+// any other, so in hard-wrapped prose the first word of a line only starts a
+// sentence when the line before ended with one. The period of an
+// abbreviation like "e.g." or of a list number at the start of a line ends
+// no sentence, and a word that already has an upper case letter, like
+// "iPhone", is left as typed. Letters are only ever raised to upper case, so
+// all-caps acronyms are left as they are.
+func capitalizeSentences(text string) string {
+	runes := []rune(text)
+	capNext := true
+	sawEnd := false
+	wordStart := -1 // first rune of the word being read, or -1 between words
+	lineStart := true
+	startsLine := false
+	for i, r := range runes {
+		switch {
+		case r == '.' || r == '!' || r == '?':
+			if r != '.' || wordStart < 0 || !periodInWord(string(runes[wordStart:i]), startsLine) {
+				sawEnd = true
+			}
+		case unicode.IsSpace(r):
+			if sawEnd {
+				capNext = true
+				sawEnd = false
+			}
+			wordStart = -1
+			if r == '\n' {
+				lineStart = true
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if wordStart < 0 {
+				wordStart = i
+				startsLine = lineStart
+			}
+			if capNext && unicode.IsLetter(r) && !hasUpper(runes[i:]) {
+				runes[i] = toUpperRune(r)
+			}
+			capNext = false
+			sawEnd = false
+		}
+		if !unicode.IsSpace(r) {
+			lineStart = false
+		}
+	}
+	return string(runes)
+}
+
+// abbreviations are the words whose trailing period doesn't end a sentence,
+// written lower case without that period.
+var abbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "etc": true, "vs": true, "cf": true,
+	"approx": true, "mr": true, "mrs": true, "ms": true, "dr": true,
+	"prof": true, "jr": true, "sr": true, "st": true,
+}
+
+// periodInWord reports whether a period after word belongs to it rather
+// than ending a sentence: word is a known abbreviation, or a number that
+// starts its line and so marks a list item like "1. item".
+func periodInWord(word string, startsLine bool) bool {
+	if abbreviations[toLower(word)] {
+		return true
+	}
+	if !startsLine {
+		return false
+	}
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasUpper reports whether the word at the start of runes, up to the first
+// rune that is neither a letter nor a digit, has an upper case letter.
+func hasUpper(runes []rune) bool {
+	for _, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func findClosestMatch(word string) (string, float64) {
+	debugf("Finding closest match for: %s", word)
+
+	if dictionary.search(word) {
+		debugf("Word '%s' found in dictionary", word)
+		return word, 1
+	}
+	if m, ok := cachedCorrection(word); ok {
+		debugf("Cached match for '%s': %s", word, m.word)
+		return m.word, m.confidence
+	}
+
+	// Swapped letters at either end and doubled or dropped letters are the
+	// most common slips and cheap to look up, so try them before the edit
+	// search. That hides the other candidates one edit away, so it is
+	// skipped when a rival must be able to stop the correction.
+	var candidates []Candidate
+	if config.Ranking != RankConservative && config.Ambiguity == "pick" {
+		candidates = edgeSwaps(word)
+		if len(candidates) == 0 {
+			candidates = runVariants(word)
+		}
+	}
+	deadline := wordDeadline()
+
+	// Check for edit distances up to the configured maximum. Farther
+	// candidates are only offered as suggestions, never applied.
+	for distance := 1; len(candidates) == 0 && distance <= min(maxDistanceFor(word), config.MaxAutoCorrectDistance); distance++ {
+		if config.BKTree {
+			var complete bool
+			candidates, complete = bkIndex.query(word, distance, deadline)
+			if !complete {
+				infof("Gave up correcting '%s' after %d ms", word, config.WordTimeout)
+				return word, 1
+			}
+			for i := range candidates {
+				node := dictionary.find(candidates[i].Word)
+				candidates[i].freq, candidates[i].rank = node.freq, node.rank
+			}
+			sortCandidates(candidates)
+		} else if scanning(distance) {
+			var complete bool
+			candidates, complete = scanCandidates(word, distance, deadline)
+			if !complete {
+				infof("Gave up correcting '%s' after %d ms", word, config.WordTimeout)
+				return word, 1
+			}
+		} else {
+			words, complete := findCandidates(word, distance, deadline)
+			if !complete {
+				infof("Gave up correcting '%s' after %d ms", word, config.WordTimeout)
+				return word, 1
+			}
+			candidates = rankCandidates(words, distance)
+		}
+		candidates = slices.DeleteFunc(candidates, func(c Candidate) bool {
+			return isRejected(word, c.Word) || !suggestable(c.Word, c.freq) || !lengthWithin(word, c.Word)
+		})
+		if len(candidates) > 0 {
+			break
+		}
+		if distance == 1 && config.SplitCompounds {
+			// Nothing one edit away, so it may be two words run together
+			if split, ok := splitCompound(word); ok {
+				debugf("Split '%s' into '%s'", word, split)
+				return split, 1
+			}
+		}
+	}
+
+	debugf("Candidates found: %v", candidates)
+
+	if len(candidates) > 1 && config.Ranking == RankConservative {
+		// All candidates share the nearest distance, so none dominates
+		debugf("Ambiguous match for '%s', leaving it unchanged", word)
+		return word, 1
+	}
+	if config.Ambiguity != "pick" && len(candidates) > 1 {
+		if tied := tiedCandidates(candidates); len(tied) > 1 {
+			debugf("Candidates for '%s' tie, leaving it unchanged", word)
+			cacheTie(word, tied)
+			return word, 1
+		}
+	}
+	if len(candidates) > 0 {
+		confidence := matchConfidence(candidates)
+		if confidence < config.MinConfidence {
+			debugf("Best match '%s' for '%s' has confidence %.2f, leaving it unchanged", candidates[0].Word, word, confidence)
+			return word, 1
+		}
+		cacheCorrection(word, candidates[0].Word, confidence)
+		return candidates[0].Word, confidence // Return the best candidate
+	}
+
+	debugf("No match found for '%s'", word)
+	return word, 1 // If no match found, return the original word
+}
+
+// matchConfidence scores the best of candidates, which all share the
+// nearest distance d, between 0 and 1:
+//
+//	confidence = share / d, share = (f₁ + 1) / Σ(fᵢ + 1)
+//
+// where fᵢ are the candidates' frequencies. A candidate with no rival at its
+// distance has a gap of at least one edit to the runner-up and a share of 1,
+// so a lone candidate one edit away scores 1. Rivals at the same distance
+// split the share by frequency, and every extra edit divides the score.
+func matchConfidence(candidates []Candidate) float64 {
+	total := 0.0
+	for _, c := range candidates {
+		total += float64(c.freq + 1)
+	}
+	share := float64(candidates[0].freq+1) / total
+	return share / float64(candidates[0].Distance)
+}
+
+// edgeSwaps returns the dictionary words made by swapping the first two or
+// the last two letters of word, as in "hte" or "adn", ranked like any other
+// candidates.
+func edgeSwaps(word string) []Candidate {
+	runes := []rune(word)
+	if len(runes) < 2 {
+		return nil
+	}
+	positions := []int{0}
+	if len(runes) > 2 {
+		positions = append(positions, len(runes)-2)
+	}
+
+	var candidates []Candidate
+	for _, i := range positions {
+		swapped := slices.Clone(runes)
+		swapped[i], swapped[i+1] = swapped[i+1], swapped[i]
+		variant := string(swapped)
+		if variant == word || isRejected(word, variant) {
+			continue
+		}
+		if node := dictionary.find(variant); node != nil && suggestable(variant, node.freq) {
+			candidates = append(candidates, Candidate{Word: variant, Distance: 1, freq: node.freq, rank: node.rank})
+		}
+	}
+	sortCandidates(candidates)
+	return candidates
+}
+
+// runVariants returns the dictionary words that differ from word only in how
+// often its letters repeat, so "runnning" finds "running" and "adress" finds
+// "address". The trie is walked along the runs of equal letters in word,
+// letting each run match between one and max(2, n) copies of its letter.
+// Variants farther than maxDistanceFor(word) or config.MaxAutoCorrectDistance
+// are dropped, and of the rest only the nearest are kept, ranked like any
+// other candidates.
+func runVariants(word string) []Candidate {
+	type run struct {
+		letter rune
+		n      int
+	}
+	var runs []run
+	for _, r := range word {
+		if len(runs) > 0 && runs[len(runs)-1].letter == r {
+			runs[len(runs)-1].n++
+			continue
+		}
+		runs = append(runs, run{r, 1})
+	}
+
+	var candidates []Candidate
+	var walk func(node *TrieNode, i int, prefix []rune)
+	walk = func(node *TrieNode, i int, prefix []rune) {
+		if i == len(runs) {
+			if !node.isEnd {
+				return
+			}
+			variant := string(prefix)
+			d := levenshteinDistance(word, variant)
+			if d > 0 && d <= min(maxDistanceFor(word), config.MaxAutoCorrectDistance) && !isRejected(word, variant) && suggestable(variant, node.freq) && lengthWithin(word, variant) {
+				candidates = append(candidates, Candidate{Word: variant, Distance: d, freq: node.freq, rank: node.rank})
+			}
+			return
+		}
+		r := runs[i]
+		for n := 1; n <= max(r.n, 2); n++ {
+			node = node.children[r.letter]
+			if node == nil {
+				return
+			}
+			prefix = append(prefix, r.letter)
+			walk(node, i+1, prefix)
+		}
+	}
+	walk(dictionary.root, 0, nil)
+	sortCandidates(candidates)
+	// Ranking and confidence expect candidates at one distance, as the
+	// edit search returns them
+	if i := slices.IndexFunc(candidates, func(c Candidate) bool { return c.Distance > candidates[0].Distance }); i >= 0 {
+		candidates = candidates[:i]
+	}
+	return candidates
+}
+
+// splitCompound tries to split a run-together word like "infact" into two
+// dictionary words. Of all valid splits it picks the one whose rarer half is
+// the most common. Single letters only count when they are "a" or "i", since
+// dictionaries tend to list every letter.
+func splitCompound(word string) (string, bool) {
+	runes := []rune(word)
+	best, bestRank := "", -1
+	for i := 1; i < len(runes); i++ {
+		left, right := string(runes[:i]), string(runes[i:])
+		if !splitPart(left) || !splitPart(right) || isRejected(word, left+" "+right) {
+			continue
+		}
+		rank := max(dictionary.find(left).rank, dictionary.find(right).rank)
+		if bestRank < 0 || rank < bestRank {
+			best, bestRank = left+" "+right, rank
+		}
+	}
+	return best, bestRank >= 0
+}
+
+// splitPart reports whether part may be one half of a split compound.
+func splitPart(part string) bool {
+	if len([]rune(part)) == 1 && part != "a" && part != "i" {
+		return false
+	}
+	return dictionary.search(part)
+}
+
+// maxDistanceFor returns the largest edit distance worth trying for word.
+// Three-letter words are only corrected at distance 1, since two edits can
+// turn them into almost any other short word.
+func maxDistanceFor(word string) int {
+	if len([]rune(word)) <= 3 {
+		return min(1, config.MaxDistance)
+	}
+	return config.MaxDistance
+}
+
+// findCandidates returns up to config.MaxCandidates dictionary words within
+// maxDistance edits of word. It reports false if deadline passed first.
+func findCandidates(word string, maxDistance int, deadline time.Time) ([]string, bool) {
+	candidates := []string{}
+	complete, visited := true, 0
+	walkEdits(word, maxDistance, func(candidate string, distance int) bool {
+		if visited++; visited%deadlineCheckInterval == 0 && pastDeadline(deadline) {
+			complete = false
+			return false
+		}
+		if node := dictionary.find(candidate); node != nil && suggestable(candidate, node.freq) {
+			candidates = append(candidates, candidate)
+		}
+		return len(candidates) < config.MaxCandidates
+	})
+	return candidates, complete
+}
+
+// scanMaxWords is the dictionary size up to which the auto strategy compares
+// a misspelling with every word instead of generating edits. It is only
+// done two or more edits away. BenchmarkScanStrategy, on eight-letter or
+// longer typos with two wrong letters, has scanning about 4x faster with
+// 1,000 words and 1.7x with 3,000, slightly slower at 6,000 and over 15x
+// slower with all of big_dic.txt.
+const scanMaxWords = 3000
+
+// scanning reports whether findClosestMatch should look for candidates
+// distance edits away with scanCandidates rather than findCandidates. That
+// follows config.Strategy, or for "auto" the size of the dictionary.
+func scanning(distance int) bool {
+	switch config.Strategy {
+	case "scan":
+		return true
+	case "edits":
+		return false
+	}
+	return distance >= 2 && dictionary.words <= scanMaxWords
+}
+
+// scanCandidates compares word with every dictionary word no more than
+// maxDistance runes longer, returning those within maxDistance edits ranked
+// by distance and then frequency. It reports false if deadline passed
+// first.
+func scanCandidates(word string, maxDistance int, deadline time.Time) ([]Candidate, bool) {
+	target := []rune(word)
+	var candidates []Candidate
+	visited := 0
+	var walk func(node *TrieNode, prefix []rune) bool
+	walk = func(node *TrieNode, prefix []rune) bool {
+		if node.isEnd {
+			if visited++; visited%deadlineCheckInterval == 0 && pastDeadline(deadline) {
+				return false
+			}
+			if d, ok := alignmentWithin(target, prefix, maxDistance); ok && d > 0 {
+				if candidate := string(prefix); suggestable(candidate, node.freq) {
+					candidates = append(candidates, Candidate{Word: candidate, Distance: d, freq: node.freq, rank: node.rank})
+				}
+			}
+		}
+		if len(prefix) >= len(target)+maxDistance {
+			return true // longer words are too far away
+		}
+		for ch, child := range node.children {
+			if !walk(child, append(prefix, ch)) {
+				return false
+			}
+		}
+		return true
+	}
+	complete := walk(dictionary.root, nil)
+	sortCandidates(candidates)
+	return candidates, complete
+}
+
+// suggestable reports whether word, with frequency freq, may be offered as a
+// correction. Stop words are never suggested, and with config.MinFrequency
+// set neither are rarer words, though both stay valid when typed. The
+// frequency filter only applies to dictionaries that have frequency counts.
+func suggestable(word string, freq int) bool {
+	if stopWords[word] {
+		return false
+	}
+	return config.MinFrequency <= 0 || !dictionary.hasFreq || freq > config.MinFrequency
+}
+
+// lengthWithin reports whether candidate is at most config.LengthTolerance
+// letters longer or shorter than word, so that "cat" can't become "category"
+// even with a generous edit distance. A tolerance of 0 allows any length.
+func lengthWithin(word, candidate string) bool {
+	if config.LengthTolerance <= 0 {
+		return true
+	}
+	diff := utf8.RuneCountInString(candidate) - utf8.RuneCountInString(word)
+	return max(diff, -diff) <= config.LengthTolerance
+}
+
+// deadlineCheckInterval is how many candidates are looked at between
+// checks of the clock.
+const deadlineCheckInterval = 1024
+
+// wordDeadline returns when the correction of a single word should give
+// up, or the zero time if config.WordTimeout is 0.
+func wordDeadline() time.Time {
+	if config.WordTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(config.WordTimeout) * time.Millisecond)
+}
+
+// pastDeadline reports whether a deadline from wordDeadline has passed.
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// walkEdits calls visit for every distinct string within maxDistance edits
+// of word, nearest first, until visit returns false. Each level applies the
+// full edit set to every variant of the previous level, so double typos
+// such as two insertions are covered.
+func walkEdits(word string, maxDistance int, visit func(candidate string, distance int) bool) {
+	seen := map[string]bool{word: true}
+	level := []string{word}
+	for distance := 1; distance <= maxDistance && len(level) > 0; distance++ {
+		var next []string
+		for _, w := range level {
+			for _, newWord := range edits(w) {
+				if seen[newWord] {
+					continue
+				}
+				seen[newWord] = true
+				if !visit(newWord, distance) {
+					return
+				}
+				if distance < maxDistance {
+					next = append(next, newWord)
+				}
+			}
+		}
+		level = next
+	}
+}
+
+// Candidate is a dictionary word suggested as a replacement.
+type Candidate struct {
+	Word     string
+	Distance int
+	freq     int
+	rank     int
+}
+
+// findSuggestions returns up to limit dictionary words within maxDistance
+// edits of word, ranked by distance and then frequency, so callers can
+// present the top N alternatives. Once limit words are found no farther
+// distance is searched, and when deadline passes the words found so far are
+// returned. The caller must hold dictionaryMu.
+func findSuggestions(word string, maxDistance, limit int, deadline time.Time) []Candidate {
+	if limit <= 0 {
+		return nil
+	}
+	var candidates []Candidate
+	visited := 0
+	walkEdits(word, maxDistance, func(candidate string, distance int) bool {
+		if len(candidates) >= limit && distance > candidates[len(candidates)-1].Distance {
+			return false
+		}
+		if visited++; visited%deadlineCheckInterval == 0 && pastDeadline(deadline) {
+			infof("Gave up looking for suggestions for '%s' after %d ms", word, config.WordTimeout)
+			return false
+		}
+		if node := dictionary.find(candidate); node != nil && !stopWords[candidate] && lengthWithin(word, candidate) {
+			candidates = append(candidates, Candidate{Word: candidate, Distance: distance, freq: node.freq, rank: node.rank})
+		}
+		return true
+	})
+	sortCandidates(candidates)
+	return candidates[:min(limit, len(candidates))]
+}
+
+// Check reports whether word is in the dictionary and, if it isn't, returns
+// up to n suggestions ranked by distance and then frequency, including ones
+// too far away to be applied automatically. It is safe for concurrent use.
+func Check(word string, n int) (ok bool, suggestions []string) {
+	lower := toLower(strings.ReplaceAll(word, "’", "'"))
+
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+	if dictionary.search(lower) {
+		return true, nil
+	}
+	for _, c := range findSuggestions(lower, maxDistanceFor(lower), n, wordDeadline()) {
+		suggestions = append(suggestions, c.Word)
+	}
+	return false, suggestions
+}
+
+// rankCandidates orders words found at the given distance by frequency.
+func rankCandidates(words []string, distance int) []Candidate {
+	candidates := make([]Candidate, 0, len(words))
+	for _, w := range words {
+		node := dictionary.find(w)
+		if node == nil {
+			continue
+		}
+		candidates = append(candidates, Candidate{Word: w, Distance: distance, freq: node.freq, rank: node.rank})
+	}
+	sortCandidates(candidates)
+	return candidates
+}
+
+// sortCandidates orders candidates by distance, then by frequency count,
+// then by position in the dictionary file.
+func sortCandidates(candidates []Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.freq != b.freq {
+			return a.freq > b.freq
+		}
+		return a.rank < b.rank
+	})
+}
+
+// edits returns the strings one edit away from word: deletions,
+// insertions, substitutions and transpositions. Inserted and substituted
+// letters come from the dictionary's own alphabet, so a German word list
+// can turn "koln" into "köln". They are only generated where the dictionary
+// has a word starting with the new prefix; edits further right never make
+// up for a prefix no word has, and the edit walk applies every combination
+// of edits in left to right order too, so nothing reachable is lost. The
+// caller must hold dictionaryMu.
+func edits(word string) []string {
+	// starts holds the byte offset of each rune plus len(word), and
+	// prefixes[i] is the Trie node for word[:starts[i]], or nil when no
+	// dictionary word starts with it
+	starts := make([]int, 0, len(word)+1)
+	prefixes := make([]*TrieNode, 0, len(word)+1)
+	node := dictionary.root
+	for i, r := range word {
+		starts = append(starts, i)
+		prefixes = append(prefixes, node)
+		if node != nil {
+			node = node.children[r]
+		}
+	}
+	starts = append(starts, len(word))
+	prefixes = append(prefixes, node)
+
+	var result []string
+	for k, i := range starts {
+		last := k == len(starts)-1
+
+		// Deletions
+		if !last {
+			result = append(result, word[:i]+word[starts[k+1]:])
+		}
+
+		if prefix := prefixes[k]; prefix != nil {
+			for _, ch := range dictionary.alphabet {
+				if prefix.children[ch] == nil {
+					continue
+				}
+				// Insertions
+				result = append(result, word[:i]+string(ch)+word[i:])
+				// Substitutions
+				if !last {
+					result = append(result, word[:i]+string(ch)+word[starts[k+1]:])
+				}
+			}
+		}
+
+		// Transpositions
+		if k < len(starts)-2 {
+			j, end := starts[k+1], starts[k+2]
+			result = append(result, word[:i]+word[j:end]+word[i:j]+word[end:])
+		}
+	}
+	return result
 }
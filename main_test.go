@@ -0,0 +1,592 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useWords replaces the dictionary with words and resets the config and
+// the learned words and corrections to their defaults for the rest of the
+// test.
+func useWords(t testing.TB, words ...string) {
+	t.Helper()
+	savedConfig, savedDictionary, savedBK, savedRejected, savedAccepted := config, dictionary, bkIndex, rejected, accepted
+	t.Cleanup(func() {
+		config, dictionary, bkIndex, rejected, accepted = savedConfig, savedDictionary, savedBK, savedRejected, savedAccepted
+		applyConfig()
+		clearMatchCache()
+	})
+
+	config = defaultConfig()
+	dir := t.TempDir()
+	config.AcceptedFile = filepath.Join(dir, "accepted.txt")
+	config.RejectedFile = filepath.Join(dir, "rejected.txt")
+	applyConfig()
+	if err := loadStopWords(""); err != nil {
+		t.Fatal(err)
+	}
+	dictionary, bkIndex, rejected, accepted = newTrieFromWords(words), newBKTree(), map[string]map[string]bool{}, map[string]int{}
+	clearMatchCache()
+}
+
+// benchDictionary is big_dic.txt with its BK-tree, read once and shared
+// by the benchmarks.
+var benchDictionary = sync.OnceValues(func() (*Trie, *BKTree) {
+	saved := config
+	defer func() { config = saved }()
+	config.BKTree, config.DictionaryDir = true, ""
+	trie, bk, err := buildDictionary("big_dic.txt")
+	if err != nil {
+		panic(err)
+	}
+	return trie, bk
+})
+
+// useBenchDictionary is useWords with the shared benchmark dictionary.
+func useBenchDictionary(b *testing.B) {
+	b.Helper()
+	useWords(b)
+	dictionary, bkIndex = benchDictionary()
+	b.ResetTimer()
+}
+
+// benchMisspellings are keyboard slips of common words: swapped, dropped
+// and extra letters. big_dic.txt lists many frequent misspellings, such as
+// those in typos.txt, as words, so these were picked from slips it doesn't.
+var benchMisspellings = strings.Fields(`
+	hwere thinkg wolud aroudn befroe buisnes dfferent evrey gorup hlep
+	imporatnt mkae mnoey nubmer porblem prgoram rihgt sevral smoething stduent
+	sysetm thnig todya untl veyr watn wrok lokoing srping wriitng
+	mroning keybaord quikcly langth bcak cahnge dcoument eveyrone flaot ifnal
+	lveel mahcine nmae oepn pciture qiuet rnuning sercet tkae udner
+	vlaue wrold xeample yselterday zreo brigde cnetral dinenr ecnomy
+`)
+
+// benchTypos returns benchMisspellings, failing b if the dictionary knows
+// any of them, since a benchmark of exact lookups measures nothing.
+func benchTypos(b *testing.B) []string {
+	b.Helper()
+	for _, typo := range benchMisspellings {
+		if dictionary.search(typo) {
+			b.Fatalf("%q is in the dictionary", typo)
+		}
+	}
+	return benchMisspellings
+}
+
+// assertCorrects checks that correctText turns each key of cases into its
+// value.
+func assertCorrects(t *testing.T, cases map[string]string) {
+	t.Helper()
+	for in, want := range cases {
+		if got, _ := correctText(in); got != want {
+			t.Errorf("correctText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestContractions(t *testing.T) {
+	useWords(t, "going", "been", "there", "home")
+	assertCorrects(t, map[string]string{
+		"im going":        "I'm going",
+		"Im going":        "I'm going",
+		"ive been there":  "I've been there",
+		"dont go home":    "don't go home",
+		"it’s, dont":      "it’s, don’t",
+		"youre home, im.": "you're home, I'm.",
+	})
+}
+
+func TestContractionsSkipKnownWords(t *testing.T) {
+	useWords(t, "im", "haus", "going")
+	acceptWord("ive")
+	assertCorrects(t, map[string]string{
+		"im haus":    "im haus",
+		"ive going":  "ive going",
+		"dont going": "don't going",
+	})
+}
+
+func TestRunVariantsAutoCorrectDistance(t *testing.T) {
+	useWords(t, "address", "running")
+	assertCorrects(t, map[string]string{
+		"adress":    "address",
+		"adresss":   "adresss",
+		"addrresss": "addrresss",
+		"runnning":  "running",
+	})
+	config.MaxAutoCorrectDistance = 2
+	clearMatchCache()
+	assertCorrects(t, map[string]string{"adresss": "address"})
+}
+
+func TestRunVariantsNearestOnly(t *testing.T) {
+	useWords(t, "running", "runing")
+	config.MaxAutoCorrectDistance = 2
+	config.Ranking = RankConservative
+	if got, confidence := correctWord("runnning", '\''); got != "running" || confidence != 1 {
+		t.Errorf("correctWord(%q) = %q, %.2f, want %q, 1", "runnning", got, confidence, "running")
+	}
+}
+
+func TestEdgeSwapsRivals(t *testing.T) {
+	useWords(t, "the", "he", "hue", "hate")
+	assertCorrects(t, map[string]string{"hte": "the"})
+
+	config.Ranking = RankConservative
+	clearMatchCache()
+	assertCorrects(t, map[string]string{"hte": "hte"})
+
+	config.Ranking, config.Ambiguity = RankAggressive, "leave"
+	clearMatchCache()
+	_, _, ambiguous := correctTextReport("hte")
+	if len(ambiguous) != 1 || len(ambiguous[0].Alternatives) < 2 {
+		t.Errorf("correctTextReport(%q) ambiguous = %+v, want the rivals of \"the\"", "hte", ambiguous)
+	}
+}
+
+func TestCheckSuggestionLimits(t *testing.T) {
+	useWords(t, "hello", "help", "hell", "yellow")
+	if ok, suggestions := Check("helo", 2); ok || len(suggestions) != 2 {
+		t.Errorf("Check(helo, 2) = %v, %v, want two suggestions", ok, suggestions)
+	}
+
+	if testing.Short() {
+		t.Skip("loads big_dic.txt")
+	}
+	dictionary, bkIndex = benchDictionary()
+	config.WordTimeout = 50
+	start := time.Now()
+	ok, suggestions := Check("misspellingsz", 5)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Check took %v with a %d ms word timeout", elapsed, config.WordTimeout)
+	}
+	if ok || len(suggestions) == 0 {
+		t.Errorf("Check(misspellingsz) = %v, %v, want the suggestions found in time", ok, suggestions)
+	}
+}
+
+func TestCorrectTextKeepsListLayout(t *testing.T) {
+	useWords(t, "shopping", "list", "milk", "bread", "eggs", "and", "cheese")
+	in := "Shoping list:\n\n  - milk\n  - bred\tand  eggs\r\n\t* chese\n\n1. milk  \n"
+	want := "Shopping list:\n\n  - milk\n  - bread\tand  eggs\r\n\t* cheese\n\n1. milk  \n"
+	if got, _ := correctText(in); got != want {
+		t.Errorf("correctText(%q) =\n%q, want\n%q", in, got, want)
+	}
+}
+
+func TestCorrectTextUnchangedWithoutCorrections(t *testing.T) {
+	useWords(t, "a", "word", "and", "more", "here", "dog", "it's", "well", "known", "café")
+	for _, in := range []string{
+		"",
+		"   ",
+		selfTestUnchanged,
+		"word\r\n\r\nmore\r\n",
+		"\t\t(a)  [word]  {more}\n",
+		"it's well-known -- a café, here… more?!",
+		"“word” ‘more’ — and/more\u00a0here\u200b",
+		"A WORD, mp3 and 42.",
+	} {
+		got, changes := correctText(in)
+		if got != in || len(changes) != 0 {
+			t.Errorf("correctText(%q) = %q with %d changes, want it unchanged", in, got, len(changes))
+		}
+	}
+}
+
+func TestCorrectTextRepeatedTypo(t *testing.T) {
+	useWords(t, "the", "cat", "sat", "on", "mat")
+	in := "teh cat sat on teh mat"
+	got, changes := correctText(in)
+	if want := "the cat sat on the mat"; got != want {
+		t.Errorf("correctText(%q) = %q, want %q", in, got, want)
+	}
+	if len(changes) != 2 || changes[0].Offset != 0 || changes[1].Offset != 15 {
+		t.Errorf("changes = %+v, want teh → the at offsets 0 and 15", changes)
+	}
+}
+
+func TestCorrectTextSkipsAlphanumeric(t *testing.T) {
+	useWords(t, "room", "map", "bob", "play", "the", "in")
+	for _, in := range []string{"mp3", "room101", "B2B", "play the mp3 in room101"} {
+		if got, _ := correctText(in); got != in {
+			t.Errorf("correctText(%q) = %q, want it unchanged", in, got)
+		}
+	}
+}
+
+func TestCorrectTextKeepsUnits(t *testing.T) {
+	useWords(t, "km", "kg", "nd", "th", "am", "pm", "hours", "and", "the", "run")
+	for _, in := range []string{"100km", "2nd", "24h", "10kg", "3pm", "5th", "a 100km run, 2nd in 24h."} {
+		if got, _ := correctText(in); got != in {
+			t.Errorf("correctText(%q) = %q, want it unchanged", in, got)
+		}
+	}
+}
+
+func TestCorrectTextHyphenated(t *testing.T) {
+	useWords(t, "state", "of", "the", "art", "well", "known")
+	assertCorrects(t, map[string]string{
+		"state-of-the-atr":   "state-of-the-art",
+		"well-knwn":          "well-known",
+		"a state--of-art":    "a state--of-art",
+		"stat-of-the-art, 2": "state-of-the-art, 2",
+	})
+}
+
+// benchDocument returns a text of n words from big_dic.txt's most common
+// ones, with every fourth word replaced by one of benchTypos.
+func benchDocument(b *testing.B, n int) string {
+	b.Helper()
+	common := strings.Fields("the of and to in is that for it as was with be by on not he this are or")
+	typos := benchTypos(b)
+	words := make([]string, n)
+	for i := range words {
+		if i%4 == 3 {
+			words[i] = typos[i/4%len(typos)]
+		} else {
+			words[i] = common[i%len(common)]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// BenchmarkCorrectWords compares correcting a 2,000 word document with a
+// quarter of its words misspelled on the worker pool with correcting it one
+// word after another.
+func BenchmarkCorrectWords(b *testing.B) {
+	useBenchDictionary(b)
+	text := benchDocument(b, 2000)
+	tokens := tokenize(text)
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			clearMatchCache()
+			for _, tok := range tokens {
+				correctWord(text[tok.start:tok.end], '\'')
+			}
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			clearMatchCache()
+			correctWords(text, tokens, '\'')
+		}
+	})
+}
+
+func TestCorrectTextPossessives(t *testing.T) {
+	useWords(t, "the", "dog", "bone", "student", "students", "books", "john")
+	assertCorrects(t, map[string]string{
+		"teh dog's bone":       "the dog's bone",
+		"the studentss' books": "the students' books",
+		"teh dgo's bone":       "the dog's bone",
+		"Jonh's books":         "John's books",
+		"the dog’s bone":       "the dog’s bone",
+	})
+}
+
+func BenchmarkBuildDictionary(b *testing.B) {
+	useWords(b)
+	config.DictionaryDir = ""
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := buildDictionary("big_dic.txt"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCorrectWord(b *testing.B) {
+	useBenchDictionary(b)
+	typos := benchTypos(b)
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+	for i := 0; i < b.N; i++ {
+		clearMatchCache()
+		for _, typo := range typos {
+			correctWord(typo, '\'')
+		}
+	}
+}
+
+func BenchmarkCorrectText(b *testing.B) {
+	useBenchDictionary(b)
+	text := benchDocument(b, 200)
+	for i := 0; i < b.N; i++ {
+		clearMatchCache()
+		correctText(text)
+	}
+}
+
+// BenchmarkScanStrategy compares scanCandidates with findCandidates two
+// edits away on dictionaries made of the most common words of big_dic.txt,
+// for misspellings of their words of eight letters or more with two letters
+// replaced. It is how scanMaxWords was chosen.
+func BenchmarkScanStrategy(b *testing.B) {
+	data, err := os.ReadFile("big_dic.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	lines := strings.Fields(string(data))
+	for _, n := range []int{100, 1000, 3000, 6000, 160000} {
+		useWords(b, lines[:n]...)
+		var typos []string
+		for _, word := range lines[:n] {
+			if runes := []rune(word); len(runes) >= 8 && len(typos) < 20 {
+				runes[1], runes[len(runes)-2] = 'q', 'z'
+				if typo := string(runes); !dictionary.search(typo) {
+					typos = append(typos, typo)
+				}
+			}
+		}
+		b.Run(fmt.Sprintf("words=%d/scan", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, typo := range typos {
+					scanCandidates(typo, 2, time.Time{})
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("words=%d/edits", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, typo := range typos {
+					findCandidates(typo, 2, time.Time{})
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCorrectBatch compares correcting 100 short texts with one
+// CorrectBatch call with correcting them one correctSpelling call at a time.
+func BenchmarkCorrectBatch(b *testing.B) {
+	useBenchDictionary(b)
+	typos := benchTypos(b)
+	texts := make([]string, 100)
+	for i := range texts {
+		texts[i] = "the " + typos[i%len(typos)] + " of it"
+	}
+	b.ResetTimer()
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			clearMatchCache()
+			CorrectBatch(texts)
+		}
+	})
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			clearMatchCache()
+			for _, text := range texts {
+				correctSpelling(text)
+			}
+		}
+	})
+}
+
+// buildTestDictionary writes data to a file called name in a temporary
+// directory and builds a dictionary from it.
+func buildTestDictionary(t *testing.T, name string, data []byte) *Trie {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config.DictionaryDir = ""
+	trie, _, err := buildDictionary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trie
+}
+
+func TestBuildDictionaryGzip(t *testing.T) {
+	useWords(t)
+	var buf strings.Builder
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello\nworld 12\n"))
+	zw.Close()
+
+	for _, name := range []string{"words.txt.gz", "words.bin"} {
+		trie := buildTestDictionary(t, name, []byte(buf.String()))
+		if !trie.search("hello") || !trie.search("world") || trie.find("world").freq != 12 {
+			t.Errorf("%s: hello and world 12 not loaded from gzip data", name)
+		}
+	}
+	if trie := buildTestDictionary(t, "plain.txt", []byte("hello\n")); !trie.search("hello") {
+		t.Error("plain word list not loaded")
+	}
+}
+
+func TestBuildDictionaryMessyFile(t *testing.T) {
+	useWords(t)
+	data := []byte("\ufeffhello\r\n  world  \r\n\r\n# a comment\r\n\t\r\nsmall 3\r\ne.g.\r\n")
+	trie := buildTestDictionary(t, "messy.txt", data)
+	for _, word := range []string{"hello", "world", "small", "e.g."} {
+		if !trie.search(word) {
+			t.Errorf("%q not loaded", word)
+		}
+	}
+	if trie.words != 4+len(contractions) || trie.search("# a comment") || trie.search("\ufeffhello") {
+		t.Errorf("loaded %d words, want the 4 real entries and the contractions", trie.words)
+	}
+
+	config.StrictDictionary = true
+	if trie := buildTestDictionary(t, "messy.txt", data); trie.search("e.g.") || !trie.search("hello") {
+		t.Error("strictDictionary should skip e.g. and keep hello")
+	}
+}
+
+func TestNewTrieFromWords(t *testing.T) {
+	trie := newTrieFromWords([]string{"the", "Paris", "NASA", "us", "US", "apple", "the", "Apple"})
+	if trie.words != 5 {
+		t.Errorf("words = %d, want 5", trie.words)
+	}
+	for rank, word := range []string{"the", "paris", "nasa", "us", "apple"} {
+		node := trie.find(word)
+		if node == nil {
+			t.Errorf("find(%q) = nil", word)
+			continue
+		}
+		if node.rank != rank {
+			t.Errorf("rank of %q = %d, want %d", word, node.rank, rank)
+		}
+	}
+	if trie.search("Paris") || trie.search("pari") || trie.search("") {
+		t.Error("search matched a word that isn't in the lowercase Trie")
+	}
+	for word, want := range map[string]string{"paris": "Paris", "nasa": "NASA", "us": "", "apple": ""} {
+		if got := trie.find(word).canonical; got != want {
+			t.Errorf("canonical of %q = %q, want %q", word, got, want)
+		}
+	}
+	if got, want := strings.Join(trie.Words(), " "), "apple NASA Paris the us"; got != want {
+		t.Errorf("Words() = %q, want %q", got, want)
+	}
+}
+
+func TestTrieClear(t *testing.T) {
+	words := []string{"hello", "help", "Paris", "köln"}
+	trie := newTrieFromWords(words)
+	trie.insertFreq("world", 7)
+	trie.Clear()
+	for _, word := range append(words, "world", "paris") {
+		if trie.search(toLower(word)) {
+			t.Errorf("search(%q) = true after Clear", word)
+		}
+	}
+	if trie.words != 0 || trie.hasFreq || len(trie.alphabet) != 0 {
+		t.Errorf("Clear left words %d, hasFreq %v, alphabet %q", trie.words, trie.hasFreq, string(trie.alphabet))
+	}
+
+	trie.insert("again")
+	if !trie.search("again") || trie.find("again").rank != 0 {
+		t.Error("a cleared Trie doesn't take new words from rank 0")
+	}
+}
+
+func TestCorrectTextAlignment(t *testing.T) {
+	useWords(t, "hello", "world", "there")
+	for _, c := range []struct{ alignment, in, want string }{
+		{"pad", "| helo  | 1 |\n| wrld | 2 |", "| hello | 1 |\n| wrld | 2 |"},
+		{"pad", "| helllo | 1 |", "| hello  | 1 |"},
+		{"pad", "| helo | 1 |", "| helo | 1 |"},
+		{"pad", "helo there", "helo there"},
+		{"pad", "helo\nthere", "hello\nthere"},
+		{"skip", "| helo  | 1 |", "| helo  | 1 |"},
+		{"truncate", "| helo | 1 |", "| hello | 1 |"}, // no longer an option
+	} {
+		config.Alignment = c.alignment
+		applyConfig()
+		if got, _ := correctText(c.in); got != c.want {
+			t.Errorf("%s: correctText(%q) = %q, want %q", c.alignment, c.in, got, c.want)
+		}
+	}
+}
+
+func TestCorrectTextAmbiguousParts(t *testing.T) {
+	useWords(t, "cut", "cost", "price", "dog")
+	config.Ambiguity = "leave"
+	for in, want := range map[string]string{
+		"cst":          "cut|cost",
+		"the cst.":     "cut|cost",
+		"cst-price":    "cut-price|cost-price",
+		"price/cst":    "price/cut|price/cost",
+		"Cst's dog":    "cut's|cost's",
+		"cst’s-price,": "cut’s-price|cost’s-price",
+	} {
+		got, _, ambiguous := correctTextReport(in)
+		if got != in {
+			t.Errorf("correctTextReport(%q) = %q, want it unchanged", in, got)
+		}
+		if len(ambiguous) != 1 || strings.Join(ambiguous[0].Alternatives, "|") != want {
+			t.Errorf("correctTextReport(%q) ambiguous = %+v, want alternatives %s", in, ambiguous, want)
+		}
+	}
+}
+
+func TestMaxCorrectionsPerRun(t *testing.T) {
+	useWords(t, "hello", "the", "world", "cut", "cost")
+	config.MaxCorrectionsPerRun = 1
+	for _, c := range []struct {
+		removeRepeats bool
+		ambiguity     string
+		in, want      string
+	}{
+		{false, "pick", "helo wrld", "hello wrld"},
+		{true, "pick", "helo the the wrld", "hello the the wrld"},
+		{true, "pick", "the the wrld", "the wrld"},
+		{false, "mark", "helo cst wrld", "hello cst wrld"},
+		{false, "mark", "cst wrld", "[?cst: cut|cost?] wrld"},
+	} {
+		config.RemoveRepeats, config.Ambiguity = c.removeRepeats, c.ambiguity
+		clearMatchCache()
+		got, changes := correctText(c.in)
+		if got != c.want || len(changes) != 1 {
+			t.Errorf("correctText(%q) = %q with %d changes, want %q with 1", c.in, got, len(changes), c.want)
+		}
+	}
+}
+
+func TestRemoveRepeatsChangeSpan(t *testing.T) {
+	useWords(t, "one", "the", "end")
+	config.RemoveRepeats = true
+	in := "one the  the end"
+	got, changes := correctText(in)
+	if want := "one the end"; got != want {
+		t.Fatalf("correctText(%q) = %q, want %q", in, got, want)
+	}
+	if len(changes) != 1 || changes[0].Offset != 7 || changes[0].Original != "  the" {
+		t.Fatalf("changes = %+v, want the repeat and the spaces before it at offset 7", changes)
+	}
+	// Applying the change by offset must give the corrected text
+	c := changes[0]
+	if applied := in[:c.Offset] + c.Corrected + in[c.Offset+len(c.Original):]; applied != got {
+		t.Errorf("applying %+v gives %q, want %q", c, applied, got)
+	}
+}
+
+func TestCapitalizeSentences(t *testing.T) {
+	cases := map[string]string{
+		"hello. world":                    "Hello. World",
+		"it cost 5. then it broke":        "It cost 5. Then it broke",
+		"my iPhone. eBay sells it":        "My iPhone. eBay sells it",
+		"iPhone cases":                    "iPhone cases",
+		"fruit, e.g. apples":              "Fruit, e.g. apples",
+		"see Dr. smith, etc. later":       "See Dr. smith, etc. later",
+		"steps:\n1. open it\n2. close it": "Steps:\n1. open it\n2. close it",
+		"done.\nnext line":                "Done.\nNext line",
+	}
+	for in, want := range cases {
+		if got := capitalizeSentences(in); got != want {
+			t.Errorf("capitalizeSentences(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import "strings"
+
+// markdownCode returns the byte spans of text that Markdown mode leaves
+// alone: fenced code blocks, inline code, link targets and autolinks. Only
+// the prose around them is corrected.
+func markdownCode(text string) []token {
+	var spans []token
+	fence := "" // the opening ``` or ~~~ while inside a fenced block
+	fenceStart := 0
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd := strings.IndexByte(text[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += lineStart + 1
+		}
+		line := strings.TrimLeft(text[lineStart:lineEnd], " \t")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				spans = append(spans, token{fenceStart, lineEnd})
+				fence = ""
+			}
+		case strings.HasPrefix(line, "```"), strings.HasPrefix(line, "~~~"):
+			fence, fenceStart = line[:3], lineStart
+		default:
+			spans = append(spans, markdownInline(text, lineStart, lineEnd)...)
+		}
+		lineStart = lineEnd
+	}
+	if fence != "" {
+		spans = append(spans, token{fenceStart, len(text)}) // unclosed fence runs to the end
+	}
+	return spans
+}
+
+// markdownInline finds inline code, link targets and autolinks in the line
+// text[start:end].
+func markdownInline(text string, start, end int) []token {
+	var spans []token
+	for i := start; i < end; i++ {
+		switch {
+		case text[i] == '`':
+			// A run of backticks is closed by the next run of the same length
+			n := backtickRun(text, i, end)
+			for j := i + n; j < end; j++ {
+				if text[j] != '`' {
+					continue
+				}
+				m := backtickRun(text, j, end)
+				if m == n {
+					spans = append(spans, token{i, j + m})
+					n = j + m - i
+					break
+				}
+				j += m - 1
+			}
+			i += n - 1
+		case strings.HasPrefix(text[i:end], "]("):
+			if k := strings.IndexByte(text[i:end], ')'); k >= 0 {
+				spans = append(spans, token{i + 1, i + k + 1})
+				i += k
+			}
+		case text[i] == '<' && (strings.HasPrefix(text[i+1:end], "http://") || strings.HasPrefix(text[i+1:end], "https://")):
+			if k := strings.IndexByte(text[i:end], '>'); k >= 0 {
+				spans = append(spans, token{i, i + k + 1})
+				i += k
+			}
+		}
+	}
+	return spans
+}
+
+// backtickRun returns how many backticks follow text[i] up to end.
+func backtickRun(text string, i, end int) int {
+	n := 0
+	for i+n < end && text[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// excludeSpans cuts the parts covered by spans out of tokens, splitting a
+// token that is only partly covered. Both lists must be in text order.
+func excludeSpans(tokens, spans []token) []token {
+	var out []token
+	s := 0
+	for _, tok := range tokens {
+		for s < len(spans) && spans[s].end <= tok.start {
+			s++
+		}
+		start := tok.start
+		for k := s; k < len(spans) && spans[k].start < tok.end; k++ {
+			if spans[k].start > start {
+				out = append(out, token{start, spans[k].start})
+			}
+			start = max(start, spans[k].end)
+		}
+		if start < tok.end {
+			out = append(out, token{start, tok.end})
+		}
+	}
+	return out
+}
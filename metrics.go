@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters exported by /metrics in the Prometheus text format.
+var (
+	correctionsTotal     atomic.Int64
+	wordsCheckedTotal    atomic.Int64
+	clipboardErrorsTotal atomic.Int64
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the correction
+// latency histogram.
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// correctionLatency is a histogram of how long each correctText call took.
+var correctionLatency struct {
+	sync.Mutex
+	counts []int64 // per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	count  int64
+}
+
+// observeCorrection records one correctText call in the metrics.
+func observeCorrection(words, corrections int, elapsed time.Duration) {
+	wordsCheckedTotal.Add(int64(words))
+	correctionsTotal.Add(int64(corrections))
+
+	seconds := elapsed.Seconds()
+	correctionLatency.Lock()
+	defer correctionLatency.Unlock()
+	if correctionLatency.counts == nil {
+		correctionLatency.counts = make([]int64, len(latencyBuckets)+1)
+	}
+	i := 0
+	for i < len(latencyBuckets) && seconds > latencyBuckets[i] {
+		i++
+	}
+	correctionLatency.counts[i]++
+	correctionLatency.sum += seconds
+	correctionLatency.count++
+}
+
+// clipboardError counts a failed clipboard read or write.
+func clipboardError() {
+	clipboardErrorsTotal.Add(1)
+}
+
+// handleMetrics writes the counters and the latency histogram in the
+// Prometheus text exposition format.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeCounter(w, "spellchecker_corrections_total", "Words replaced by a correction.", correctionsTotal.Load())
+	writeCounter(w, "spellchecker_words_checked_total", "Words looked at for correction.", wordsCheckedTotal.Load())
+	writeCounter(w, "spellchecker_clipboard_errors_total", "Failed clipboard reads and writes.", clipboardErrorsTotal.Load())
+
+	correctionLatency.Lock()
+	defer correctionLatency.Unlock()
+	const name = "spellchecker_correction_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Time taken to correct one text.\n# TYPE %s histogram\n", name, name)
+	cumulative := int64(0)
+	for i, bound := range latencyBuckets {
+		if correctionLatency.counts != nil {
+			cumulative += correctionLatency.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, correctionLatency.count)
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, correctionLatency.sum, name, correctionLatency.count)
+}
+
+// writeCounter writes one counter with its HELP and TYPE lines.
+func writeCounter(w io.Writer, name, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+}
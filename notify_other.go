@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+// notify shows a desktop notification through osascript on macOS and
+// notify-send elsewhere.
+func notify(title, message string) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		script := "display notification " + strconv.Quote(message) + " with title " + strconv.Quote(title)
+		cmd = exec.Command("osascript", "-e", script)
+	} else {
+		cmd = exec.Command("notify-send", title, message)
+	}
+	if err := cmd.Run(); err != nil {
+		warnf("Failed to show notification: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/lxn/win"
+)
+
+var findWindowEx = user32.NewProc("FindWindowExW")
+
+const (
+	// systrayClass and systrayIconID identify the notification icon that
+	// github.com/getlantern/systray registers for this process.
+	systrayClass  = "SystrayClass"
+	systrayIconID = 100
+)
+
+// notify shows a balloon on the tray icon. Windows 10 and later display it
+// as a toast.
+func notify(title, message string) {
+	hwnd := systrayWindow()
+	if hwnd == 0 {
+		warnf("Tray window not found, cannot show notification")
+		return
+	}
+	nid := win.NOTIFYICONDATA{
+		HWnd:        hwnd,
+		UID:         systrayIconID,
+		UFlags:      win.NIF_INFO,
+		DwInfoFlags: win.NIIF_INFO,
+	}
+	nid.CbSize = uint32(unsafe.Sizeof(nid))
+	copyUTF16(nid.SzInfoTitle[:], title)
+	copyUTF16(nid.SzInfo[:], message)
+	if !win.Shell_NotifyIcon(win.NIM_MODIFY, &nid) {
+		warnf("Failed to show notification")
+	}
+}
+
+// systrayWindow finds the hidden window owning this process's tray icon,
+// skipping windows of the same class that belong to other programs.
+func systrayWindow() win.HWND {
+	class, _ := syscall.UTF16PtrFromString(systrayClass)
+	pid := uint32(os.Getpid())
+	var hwnd uintptr
+	for {
+		hwnd, _, _ = findWindowEx.Call(0, hwnd, uintptr(unsafe.Pointer(class)), 0)
+		if hwnd == 0 {
+			return 0
+		}
+		var owner uint32
+		win.GetWindowThreadProcessId(win.HWND(hwnd), &owner)
+		if owner == pid {
+			return win.HWND(hwnd)
+		}
+	}
+}
+
+// copyUTF16 copies s into a fixed-size NUL-terminated buffer, truncating
+// it if needed.
+func copyUTF16(dst []uint16, s string) {
+	units, _ := syscall.UTF16FromString(s)
+	if len(units) > len(dst) {
+		units = units[:len(dst)]
+		units[len(units)-1] = 0
+	}
+	copy(dst, units)
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// openFile opens path with open on macOS and xdg-open elsewhere.
+func openFile(path string) {
+	opener := "xdg-open"
+	if runtime.GOOS == "darwin" {
+		opener = "open"
+	}
+	if err := exec.Command(opener, path).Start(); err != nil {
+		errorf("Failed to open %s: %v", path, err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"syscall"
+
+	"github.com/lxn/win"
+)
+
+// openFile opens path with the program associated with its file type.
+func openFile(path string) {
+	verb, _ := syscall.UTF16PtrFromString("open")
+	file, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		errorf("Failed to open %s: %v", path, err)
+		return
+	}
+	if !win.ShellExecute(0, verb, file, nil, nil, win.SW_SHOWNORMAL) {
+		errorf("Failed to open %s", path)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// checkProcs has nothing to check outside Windows, where the clipboard is
+// reached through external commands.
+func checkProcs() error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// requiredProcs lists the Win32 functions the tray app calls, so that they
+// can be resolved up front instead of panicking on first use.
+var requiredProcs = []*syscall.LazyProc{
+	getClipboardData, openClipboard, closeClipboard, emptyClipboard,
+	setClipboardData, enumClipboardFmt, registerClipFmt,
+	globalSize, multiByteToWideChar, wideCharToMultiByte,
+	getGUIThreadInfo,
+	registerHotKey, unregisterHotKey, getMessage, peekMessage, postThreadMessage,
+	keybdEvent, getClipboardSequenceNumber,
+	findWindowEx,
+}
+
+// checkProcs resolves every required procedure and returns an error naming
+// each one that is missing, as happens on some Wine versions.
+func checkProcs() error {
+	var errs []error
+	for _, proc := range requiredProcs {
+		if err := proc.Find(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// reviewFile steps through every unknown word in the file at inPath,
+// prompting on stderr with the word in context and numbered suggestions,
+// and writes the text with the chosen replacements to outPath, or to stdout
+// when outPath is empty.
+func reviewFile(inPath, outPath string) error {
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		return err
+	}
+	text := string(data)
+	reviewed, err := reviewText(text, bufio.NewReader(os.Stdin), os.Stderr)
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		_, err = os.Stdout.WriteString(reviewed)
+		return err
+	}
+	return os.WriteFile(outPath, []byte(reviewed), 0o644)
+}
+
+// reviewText asks about each unknown word of text in turn. A number picks
+// that suggestion, Enter keeps the word, "e" types a replacement, "a" keeps
+// it and adds it to the accepted words, and "q" keeps the rest of the text
+// as it is.
+func reviewText(text string, in *bufio.Reader, out io.Writer) (string, error) {
+	var b strings.Builder
+	last := 0
+	quit := false
+	for _, tok := range checkedTokens(text) {
+		b.WriteString(text[last:tok.start])
+		last = tok.end
+		word := text[tok.start:tok.end]
+		prefix, core, suffix := splitPunctuation(word)
+		lower := toLower(strings.ReplaceAll(core, "’", "'"))
+		if quit || !needsReview(core, lower) {
+			b.WriteString(word)
+			continue
+		}
+
+		dictionaryMu.RLock()
+		candidates := findSuggestions(lower, maxDistanceFor(lower), config.MaxCandidates, wordDeadline())
+		dictionaryMu.RUnlock()
+
+		fmt.Fprintf(out, "\n%s\n", reviewContext(text, tok))
+		for i, c := range candidates {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, matchCase(core, c.Word))
+		}
+		if len(candidates) == 0 {
+			fmt.Fprint(out, "  (no suggestions)\n[Enter] keep, e) edit, a) accept, q) quit: ")
+		} else {
+			fmt.Fprintf(out, "[Enter] keep, [1-%d] replace, e) edit, a) accept, q) quit: ", len(candidates))
+		}
+
+		replacement := core
+		for {
+			line, err := in.ReadString('\n')
+			if err != nil && line == "" {
+				return "", fmt.Errorf("read choice: %w", err)
+			}
+			choice := strings.TrimSpace(line)
+			if n, err := strconv.Atoi(choice); err == nil && n >= 1 && n <= len(candidates) {
+				replacement = matchCase(core, candidates[n-1].Word)
+				acceptWord(candidates[n-1].Word)
+				break
+			}
+			switch choice {
+			case "":
+			case "e":
+				fmt.Fprint(out, "Replacement: ")
+				edited, _ := in.ReadString('\n')
+				if edited = strings.TrimSpace(edited); edited != "" {
+					replacement = edited
+				}
+			case "a":
+				acceptWord(lower)
+			case "q":
+				quit = true
+			default:
+				fmt.Fprint(out, "Choose a number, Enter, e, a or q: ")
+				continue
+			}
+			break
+		}
+		b.WriteString(prefix + replacement + suffix)
+	}
+	b.WriteString(text[last:])
+	return b.String(), nil
+}
+
+// needsReview reports whether correctWord would try to correct the word:
+// it has letters, isn't an identifier, ignored word or acronym, and isn't
+// in the dictionary.
+func needsReview(core, lower string) bool {
+	if !strings.ContainsFunc(core, unicode.IsLetter) || isAlphanumericMixed(core) || ignoreWords[lower] {
+		return false
+	}
+	if (config.SkipAllCaps && isAllCaps(core)) || matchesSkipPattern(core) {
+		return false
+	}
+	dictionaryMu.RLock()
+	defer dictionaryMu.RUnlock()
+	return !dictionary.search(lower)
+}
+
+// reviewContext returns the line around tok with the token marked.
+func reviewContext(text string, tok token) string {
+	start := strings.LastIndexByte(text[:tok.start], '\n') + 1
+	end := strings.IndexByte(text[tok.end:], '\n')
+	if end < 0 {
+		end = len(text)
+	} else {
+		end += tok.end
+	}
+	line := text[start:tok.start] + ">>" + text[tok.start:tok.end] + "<<" + text[tok.end:end]
+	return strings.TrimRight(line, "\r")
+}
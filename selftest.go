@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// selfTestCases are corrections every usable dictionary should make. A case
+// is skipped when its expected word isn't in the dictionary.
+var selfTestCases = []struct{ typo, want string }{
+	{"helo", "hello"},
+	{"wrld", "world"},
+	{"recive", "receive"},
+	{"dont", "don't"},
+}
+
+// selfTestUnchanged is text with irregular spacing and punctuation that must
+// come back byte for byte when each of its words is spelled correctly.
+const selfTestUnchanged = "A word, and more.\n\n\tHere  (a dog)  \r\nmore!"
+
+// selfTest loads the dictionary, runs a few known corrections, resolves the
+// system functions the tray app needs and registers and unregisters the
+// hotkey, writing a line per check and a PASS/FAIL summary to out. It
+// reports whether every check passed.
+func selfTest(out io.Writer) bool {
+	failed := 0
+	report := func(status, format string, args ...any) {
+		if status == "FAIL" {
+			failed++
+		}
+		fmt.Fprintf(out, "%s  %s\n", status, fmt.Sprintf(format, args...))
+	}
+
+	trie, bk, err := buildDictionary(config.Dictionary)
+	if err != nil {
+		report("FAIL", "dictionary: %v", err)
+	} else {
+		dictionary, bkIndex = trie, bk
+		report("PASS", "dictionary: %d words from %s", trie.words, config.Dictionary)
+
+		for _, c := range selfTestCases {
+			if !strings.Contains(c.want, "'") && !dictionary.search(c.want) {
+				report("SKIP", "correction: %q is not in the dictionary", c.want)
+				continue
+			}
+			got, _ := correctText(c.typo)
+			if got != c.want {
+				report("FAIL", "correction: %s → %s, want %s", c.typo, got, c.want)
+				continue
+			}
+			report("PASS", "correction: %s → %s", c.typo, got)
+		}
+
+		if unknown := unknownWords(selfTestUnchanged); len(unknown) > 0 {
+			report("SKIP", "unchanged text: %q is not in the dictionary", unknown[0])
+		} else if got, _ := correctText(selfTestUnchanged); got != selfTestUnchanged {
+			report("FAIL", "unchanged text: %q came back as %q", selfTestUnchanged, got)
+		} else {
+			report("PASS", "unchanged text: kept byte for byte")
+		}
+	}
+
+	if err := checkProcs(); err != nil {
+		// The hotkey check would panic on a missing procedure
+		report("FAIL", "system functions: %v", err)
+		report("SKIP", "hotkey: system functions are missing")
+		return summarize(out, failed)
+	}
+	report("PASS", "system functions: all found")
+
+	switch err := checkHotkey(); {
+	case errors.Is(err, errors.ErrUnsupported):
+		report("SKIP", "hotkey: %s is only supported on Windows", config.Hotkey)
+	case err != nil:
+		report("FAIL", "hotkey: %s: %v", config.Hotkey, err)
+	default:
+		report("PASS", "hotkey: %s can be registered", config.Hotkey)
+	}
+
+	return summarize(out, failed)
+}
+
+// summarize writes the overall result of selfTest and reports whether it
+// passed.
+func summarize(out io.Writer, failed int) bool {
+	if failed > 0 {
+		fmt.Fprintf(out, "FAIL  %d check(s) failed\n", failed)
+		return false
+	}
+	fmt.Fprintln(out, "PASS")
+	return true
+}
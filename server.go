@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRequestBytes caps the size of a /correct request body.
+const maxRequestBytes = 1 << 20
+
+// correctResponse is the JSON body returned by /correct?format=json.
+type correctResponse struct {
+	Corrected string      `json:"corrected"`
+	Changes   []Change    `json:"changes"`
+	Ambiguous []Ambiguity `json:"ambiguous,omitempty"`
+}
+
+// checkResponse is the JSON body returned by /check.
+type checkResponse struct {
+	OK          bool     `json:"ok"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// suggestion is one entry of the JSON list returned by /suggest.
+type suggestion struct {
+	Word     string `json:"word"`
+	Distance int    `json:"distance"`
+}
+
+// serveAddr resolves a -serve address, binding to localhost when only a
+// port such as ":8080" is given.
+func serveAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return addr
+}
+
+// serve runs the correction HTTP API on addr. It shares the dictionary
+// loaded for the tray app.
+func serve(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/correct", handleCorrect)
+	mux.HandleFunc("/accept", handleAccept)
+	mux.HandleFunc("/suggest", handleSuggest)
+	mux.HandleFunc("/check", handleCheck)
+	mux.HandleFunc("/metrics", handleMetrics)
+
+	addr = serveAddr(addr)
+	infof("Serving corrections on http://%s/correct", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		errorf("HTTP server stopped: %v", err)
+	}
+}
+
+// handleCorrect corrects the text in the request body. The corrected text
+// is returned as plain text, or as JSON with the list of changes when
+// ?format=json is given or the client accepts application/json.
+func handleCorrect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	corrected, changes, ambiguous := correctTextReport(string(body))
+	if config.Suggest {
+		corrected = withSuggestions(string(body), changes)
+	}
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		if changes == nil {
+			changes = []Change{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(correctResponse{Corrected: corrected, Changes: changes, Ambiguous: ambiguous})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, corrected)
+}
+
+// handleSuggest lists the best config.MaxCandidates corrections for
+// ?word=, including those too far away to be applied automatically, so a
+// client can let the user pick one and report it to /accept.
+func handleSuggest(w http.ResponseWriter, r *http.Request) {
+	word := toLower(r.URL.Query().Get("word"))
+	if word == "" {
+		http.Error(w, "missing word parameter", http.StatusBadRequest)
+		return
+	}
+
+	dictionaryMu.RLock()
+	candidates := findSuggestions(word, maxDistanceFor(word), config.MaxCandidates, wordDeadline())
+	dictionaryMu.RUnlock()
+
+	suggestions := []suggestion{}
+	for _, c := range candidates {
+		suggestions = append(suggestions, suggestion{Word: c.Word, Distance: c.Distance})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+// handleCheck reports whether the word query parameter is spelled
+// correctly, with up to n suggestions (config.MaxCandidates by default) when
+// it isn't.
+func handleCheck(w http.ResponseWriter, r *http.Request) {
+	word := r.URL.Query().Get("word")
+	if word == "" {
+		http.Error(w, "missing word parameter", http.StatusBadRequest)
+		return
+	}
+	n := config.MaxCandidates
+	if s := r.URL.Query().Get("n"); s != "" {
+		var err error
+		if n, err = strconv.Atoi(s); err != nil || n < 0 {
+			http.Error(w, "invalid n parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ok, suggestions := Check(word, n)
+	if suggestions == nil {
+		suggestions = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkResponse{OK: ok, Suggestions: suggestions})
+}
+
+// handleAccept records each whitespace-separated word in the request body
+// as confirmed by the user, e.g. after it was picked from a suggestion list.
+func handleAccept(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	for _, word := range strings.Fields(string(body)) {
+		acceptWord(word)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
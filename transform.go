@@ -0,0 +1,16 @@
+package main
+
+import "strings"
+
+// outputTransforms maps each config.OutputTransform to the function applied
+// to the corrected clipboard text just before it is written back.
+var outputTransforms = map[string]func(text string) string{
+	"none": func(text string) string {
+		return text
+	},
+	"trim":      strings.TrimSpace,
+	"uppercase": toUpper,
+	"append-text": func(text string) string {
+		return text + config.AppendText
+	},
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"time"
+)
+
+// commonTypos is the bundled list of frequent misspellings, one per line
+// with "#" comments, whose corrections warmup looks up ahead of time.
+//
+//go:embed typos.txt
+var commonTypos string
+
+// matchCacheSize caps matchCache so that a long session of unusual words
+// doesn't grow it without bound.
+const matchCacheSize = 10000
+
+// matchCache holds the corrections findClosestMatch found, keyed by the
+// lowercase word. Only words that were corrected are kept, and those left
+// unchanged because candidates tied under config.Ambiguity. It has its own
+// mutex since correctWords fills it from several goroutines under the read
+// lock, and it is emptied whenever the dictionary or the rejected
+// corrections change, since either can change the best match.
+var (
+	matchCacheMu sync.Mutex
+	matchCache   = map[string]cachedMatch{}
+)
+
+// cachedMatch is a correction in matchCache. For a tie, word is the word
+// itself and alternatives are the tied candidates.
+type cachedMatch struct {
+	word         string
+	confidence   float64
+	alternatives []string
+}
+
+// cachedCorrection looks word up in matchCache.
+func cachedCorrection(word string) (cachedMatch, bool) {
+	matchCacheMu.Lock()
+	defer matchCacheMu.Unlock()
+	m, ok := matchCache[word]
+	return m, ok
+}
+
+// cacheCorrection adds the correction of word to matchCache unless it is
+// full. The caller must hold dictionaryMu, so that the correction matches
+// the current dictionary.
+func cacheCorrection(word, corrected string, confidence float64) {
+	matchCacheMu.Lock()
+	defer matchCacheMu.Unlock()
+	if len(matchCache) < matchCacheSize {
+		matchCache[word] = cachedMatch{word: corrected, confidence: confidence}
+	}
+}
+
+// cacheTie records that the candidates in tied were equally good for word,
+// unless the cache is full. correctText learns from it which words were
+// ambiguous, so a tie found once the cache is full goes unreported. The
+// caller must hold dictionaryMu.
+func cacheTie(word string, tied []string) {
+	matchCacheMu.Lock()
+	defer matchCacheMu.Unlock()
+	if len(matchCache) < matchCacheSize {
+		matchCache[word] = cachedMatch{word: word, confidence: 1, alternatives: tied}
+	}
+}
+
+// clearMatchCache empties matchCache. The caller must hold dictionaryMu for
+// writing.
+func clearMatchCache() {
+	matchCacheMu.Lock()
+	defer matchCacheMu.Unlock()
+	clear(matchCache)
+}
+
+// warmup looks up the correction of every word in commonTypos so that the
+// first check finds them in matchCache. It is meant to run in its own
+// goroutine and takes the read lock per word, so checks and reloads are
+// never held up for long.
+func warmup() {
+	start := time.Now()
+	n := 0
+	err := readLines(strings.NewReader(commonTypos), func(line string) {
+		typo := toLower(strings.TrimSpace(line))
+		if typo == "" || strings.HasPrefix(typo, "#") {
+			return
+		}
+		dictionaryMu.RLock()
+		if corrected, _ := findClosestMatch(typo); corrected != typo {
+			n++
+		}
+		dictionaryMu.RUnlock()
+	})
+	if err != nil {
+		warnf("Warmup stopped: %v", err)
+	}
+	infof("Warmed up %d correction(s) in %v", n, time.Since(start).Round(time.Millisecond))
+}
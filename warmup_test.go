@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestMatchCacheSizeCapsTies(t *testing.T) {
+	useWords(t)
+	for i := 0; len(matchCache) < matchCacheSize; i++ {
+		cacheCorrection("word"+strconv.Itoa(i), "word", 1)
+	}
+	cacheTie("cst", []string{"cut", "cost"})
+	cacheCorrection("helo", "hello", 1)
+	if len(matchCache) != matchCacheSize {
+		t.Errorf("len(matchCache) = %d, want at most %d", len(matchCache), matchCacheSize)
+	}
+	if _, ok := cachedCorrection("cst"); ok {
+		t.Error("cacheTie added to a full cache")
+	}
+}
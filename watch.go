@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// watchInterval is how often watchClipboard polls for changes.
+	watchInterval = 250 * time.Millisecond
+	// watchDebounce is how long the clipboard must stay unchanged before it
+	// is corrected, so bursts of copies are handled once.
+	watchDebounce = 500 * time.Millisecond
+)
+
+// ownWrite is the text this process last put on the clipboard, so that the
+// watcher doesn't correct its own output, or the original text put back by
+// an undo, over and over.
+var ownWrite struct {
+	sync.Mutex
+	text  string
+	valid bool
+}
+
+// noteOwnWrite records that text was just written to the clipboard by us.
+// Clipboard implementations call it after every successful write.
+func noteOwnWrite(text string) {
+	ownWrite.Lock()
+	ownWrite.text, ownWrite.valid = text, true
+	ownWrite.Unlock()
+}
+
+// consumeOwnWrite reports whether text is what we last wrote, and forgets
+// the write either way, so the same text copied later by the user is still
+// corrected.
+func consumeOwnWrite(text string) bool {
+	ownWrite.Lock()
+	defer ownWrite.Unlock()
+	own := ownWrite.valid && ownWrite.text == text
+	ownWrite.text, ownWrite.valid = "", false
+	return own
+}
+
+// watching is whether the watcher is on. It starts as config.Watch and is
+// flipped by the tray menu while watchClipboard reads it, hence atomic.
+var watching atomic.Bool
+
+// watchToggled wakes watchClipboard when watching changes, so that it can
+// sleep instead of polling while it is off.
+var watchToggled = make(chan struct{}, 1)
+
+// watchClipboard corrects the clipboard each time it changes while watching
+// is on, except for changes made by this process and content a password
+// manager marked as private. While watching is off it doesn't poll at all.
+func watchClipboard() {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		for !watching.Load() {
+			<-watchToggled
+		}
+		last := clipboardVersion()
+		pending := false
+		var changedAt time.Time
+		for range ticker.C {
+			if !watching.Load() {
+				break
+			}
+			if v := clipboardVersion(); v != last {
+				last, pending, changedAt = v, true, time.Now()
+				continue
+			}
+			if !pending || time.Since(changedAt) < watchDebounce {
+				continue
+			}
+			pending = false
+			if clipboardPrivate() {
+				debugf("Clipboard content is marked private, leaving it alone")
+				continue
+			}
+			if text, err := clipboard.Read(); err == nil && consumeOwnWrite(text) {
+				continue
+			}
+			runCheck("clipboard change", func() { correctClipboard(false) })
+		}
+	}
+}
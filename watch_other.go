@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import "hash/fnv"
+
+// clipboardVersion returns a hash of the clipboard text. The helper
+// programs have no change counter, so the content itself is compared.
+func clipboardVersion() uint64 {
+	text, err := clipboard.Read()
+	if err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return h.Sum64()
+}
+
+// clipboardPrivate reports whether the clipboard content is marked private.
+// The helper programs only hand over the text, so it never is.
+func clipboardPrivate() bool {
+	return false
+}
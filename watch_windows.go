@@ -0,0 +1,21 @@
+package main
+
+// clipboardVersion returns the clipboard sequence number, which Windows
+// increments on every change.
+func clipboardVersion() uint64 {
+	seq, _, _ := getClipboardSequenceNumber.Call()
+	return uint64(seq)
+}
+
+// clipboardPrivate reports whether the clipboard holds content that its
+// owner, typically a password manager, asked clipboard monitors to ignore.
+// A clipboard that can't be opened counts as private, so that the change
+// is skipped rather than read unchecked.
+func clipboardPrivate() bool {
+	if r, _, _ := openClipboard.Call(0); r == 0 {
+		return true
+	}
+	defer closeClipboard.Call()
+	return clipboardHas(registeredFormat("ExcludeClipboardContentFromMonitorProcessing")) ||
+		clipboardHas(registeredFormat("Clipboard Viewer Ignore"))
+}